@@ -0,0 +1,173 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kardianos/osext"
+	"github.com/uber-go/atomic"
+)
+
+// Commands supported by the control server
+const (
+	StatusCommand      = "status"
+	RestartCommand     = "restart"
+	StopChildCommand   = "stop-child"
+	StartChildCommand  = "start-child"
+	ForceUpdateCommand = "force-update"
+	TailLogsCommand    = "tail-logs"
+)
+
+// Commands is the list of supported commands
+var Commands = []string{
+	StatusCommand,
+	RestartCommand,
+	StopChildCommand,
+	StartChildCommand,
+	ForceUpdateCommand,
+	TailLogsCommand,
+}
+
+// Request defines the control request sent to the server
+type Request struct {
+	Command   string `json:"command"`
+	Lines     int    `json:"lines,omitempty"`
+	Connector string `json:"connector,omitempty"`
+}
+
+// Response defines the control response sent to the client
+type Response struct {
+	Result string `json:"result,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Handler handles a control request and returns the result
+type Handler func(request *Request) (string, error)
+
+// Server defines the interface for the control server
+type Server interface {
+	Close() error
+}
+
+type server struct {
+	listener net.Listener
+	handler  Handler
+	closed   *atomic.Bool
+}
+
+// Listen starts the control server on the control socket
+func Listen(handler Handler) (Server, error) {
+	path, err := GetSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	listener, err := listen(path)
+	if err != nil {
+		return nil, err
+	}
+	srv := &server{
+		listener: listener,
+		handler:  handler,
+		closed:   atomic.NewBool(false),
+	}
+	go srv.serve()
+	return srv, nil
+}
+
+// Close stops accepting control requests
+func (srv *server) Close() error {
+	srv.closed.Store(true)
+	return srv.listener.Close()
+}
+
+func (srv *server) serve() {
+	for {
+		conn, err := srv.listener.Accept()
+		if err != nil {
+			if srv.closed.Load() {
+				return
+			}
+			time.Sleep(time.Second)
+			continue
+		}
+		go srv.handle(conn)
+	}
+}
+
+func (srv *server) handle(conn net.Conn) {
+	defer conn.Close()
+	response := &Response{}
+	request := &Request{}
+	line, err := bufio.NewReader(conn).ReadBytes('\n')
+	if err == nil {
+		err = json.Unmarshal(line, request)
+	}
+	if err != nil {
+		response.Error = fmt.Sprintf("invalid request: %v", err.Error())
+	} else if !IsCommand(request.Command) {
+		response.Error = fmt.Sprintf("unknown command: %v", request.Command)
+	} else {
+		result, err := srv.handler(request)
+		response.Result = result
+		if err != nil {
+			response.Error = err.Error()
+		}
+	}
+	json.NewEncoder(conn).Encode(response)
+}
+
+// Send sends the request to the running ignition and returns the response
+func Send(request *Request) (*Response, error) {
+	path, err := GetSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dial(path)
+	if err != nil {
+		return nil, fmt.Errorf("ignition is not reachable on %v: %v", path, err.Error())
+	}
+	defer conn.Close()
+	err = json.NewEncoder(conn).Encode(request)
+	if err != nil {
+		return nil, err
+	}
+	response := &Response{}
+	err = json.NewDecoder(conn).Decode(response)
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// IsCommand returns true if the command is supported
+func IsCommand(command string) bool {
+	for _, supported := range Commands {
+		if command == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSocketPath returns the path of the control socket, next to service.json
+func GetSocketPath() (string, error) {
+	fullexecpath, err := osext.Executable()
+	if err != nil {
+		return "", err
+	}
+	dir, _ := filepath.Split(fullexecpath)
+	return filepath.Join(dir, "control.sock"), nil
+}
+
+func removeStaleSocket(path string) error {
+	err := os.Remove(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
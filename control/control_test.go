@@ -0,0 +1,110 @@
+package control_test
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/control"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Control", func() {
+	var srv control.Server
+	var received *control.Request
+
+	BeforeEach(func() {
+		var err error
+		received = nil
+		srv, err = control.Listen(func(request *control.Request) (string, error) {
+			received = request
+			if request.Command == control.StopChildCommand {
+				return "", fmt.Errorf("oh no")
+			}
+			return fmt.Sprintf("handled %v", request.Command), nil
+		})
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		srv.Close()
+	})
+
+	Describe("->Send", func() {
+		Describe("when called with a known command", func() {
+			var response *control.Response
+			var err error
+
+			BeforeEach(func() {
+				response, err = control.Send(&control.Request{Command: control.TailLogsCommand, Lines: 5})
+			})
+
+			It("should not have error", func() {
+				Expect(err).To(BeNil())
+			})
+
+			It("should pass the request to the handler", func() {
+				Expect(received.Command).To(Equal(control.TailLogsCommand))
+				Expect(received.Lines).To(Equal(5))
+			})
+
+			It("should return the result", func() {
+				Expect(response.Result).To(Equal("handled tail-logs"))
+				Expect(response.Error).To(BeEmpty())
+			})
+		})
+
+		Describe("when the handler returns an error", func() {
+			var response *control.Response
+
+			BeforeEach(func() {
+				response, _ = control.Send(&control.Request{Command: control.StopChildCommand})
+			})
+
+			It("should return the error", func() {
+				Expect(response.Error).To(Equal("oh no"))
+			})
+		})
+
+		Describe("when called with an unknown command", func() {
+			var response *control.Response
+
+			BeforeEach(func() {
+				response, _ = control.Send(&control.Request{Command: "explode"})
+			})
+
+			It("should not call the handler", func() {
+				Expect(received).To(BeNil())
+			})
+
+			It("should return an error", func() {
+				Expect(response.Error).To(Equal("unknown command: explode"))
+			})
+		})
+	})
+
+	Describe("->Close", func() {
+		It("should remove the socket", func() {
+			path, err := control.GetSocketPath()
+			Expect(err).To(BeNil())
+			Expect(srv.Close()).To(Succeed())
+			_, err = os.Stat(path)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		Describe("when the next ignition took over the socket", func() {
+			It("should keep its socket", func() {
+				next, err := control.Listen(func(request *control.Request) (string, error) {
+					return "next", nil
+				})
+				Expect(err).To(BeNil())
+				defer next.Close()
+				Expect(srv.Close()).To(Succeed())
+				response, err := control.Send(&control.Request{Command: control.StatusCommand})
+				Expect(err).To(BeNil())
+				Expect(response.Result).To(Equal("next"))
+			})
+		})
+	})
+})
@@ -0,0 +1,74 @@
+// +build !windows
+
+package control
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// unixListener removes the socket on Close, unless the next
+// ignition process took it over during a self update
+type unixListener struct {
+	net.Listener
+	path string
+	info os.FileInfo
+}
+
+func listen(path string) (net.Listener, error) {
+	err := removeStaleSocket(path)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := listenUnix(path)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return &unixListener{listener, path, info}, nil
+}
+
+// listenUnix creates the listener from the socket fd, the net.Listen
+// listener would always unlink the path when it is closed
+func listenUnix(path string) (net.Listener, error) {
+	fd, err := syscall.Socket(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, err
+	}
+	syscall.CloseOnExec(fd)
+	file := os.NewFile(uintptr(fd), path)
+	defer file.Close()
+	err = syscall.Bind(fd, &syscall.SockaddrUnix{Name: path})
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Listen(fd, syscall.SOMAXCONN)
+	if err != nil {
+		return nil, err
+	}
+	return net.FileListener(file)
+}
+
+// Close stops listening and removes the socket if it is still ours
+func (listener *unixListener) Close() error {
+	err := listener.Listener.Close()
+	info, statErr := os.Stat(listener.path)
+	if statErr == nil && os.SameFile(info, listener.info) {
+		os.Remove(listener.path)
+	}
+	return err
+}
+
+func dial(path string) (net.Conn, error) {
+	return net.Dial("unix", path)
+}
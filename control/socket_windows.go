@@ -0,0 +1,14 @@
+package control
+
+import (
+	"fmt"
+	"net"
+)
+
+func listen(path string) (net.Listener, error) {
+	return nil, fmt.Errorf("control socket is not supported on windows")
+}
+
+func dial(path string) (net.Conn, error) {
+	return nil, fmt.Errorf("control socket is not supported on windows")
+}
@@ -0,0 +1,108 @@
+package forever
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+	"github.com/octoblu/go-meshblu-connector-ignition/source"
+)
+
+// Release channels for ignition self updates
+const (
+	StableChannel = runner.StableChannel
+	BetaChannel   = runner.BetaChannel
+	PinnedChannel = runner.PinnedChannel
+)
+
+// GetChannel returns the configured release channel, defaults to stable
+func GetChannel(config *runner.Config) (string, error) {
+	switch config.UpdateChannel {
+	case "":
+		return StableChannel, nil
+	case StableChannel, BetaChannel:
+		return config.UpdateChannel, nil
+	case PinnedChannel:
+		if config.PinnedVersion == "" {
+			return "", fmt.Errorf("UpdateChannel pinned requires a PinnedVersion")
+		}
+		return PinnedChannel, nil
+	}
+	return "", fmt.Errorf("Unknown UpdateChannel %v", config.UpdateChannel)
+}
+
+// ShouldUpdate returns true if the ignition should update from the
+// current version to the latest version. Only the pinned channel
+// allows downgrades, the others must stay in the min / max range
+func ShouldUpdate(config *runner.Config, currentVersion, latestVersion string) (bool, error) {
+	if latestVersion == "" {
+		return false, nil
+	}
+	current, err := parseVersion(currentVersion)
+	if err != nil {
+		return false, err
+	}
+	latest, err := parseVersion(latestVersion)
+	if err != nil {
+		return false, err
+	}
+	if !current.LessThan(*latest) && !latest.LessThan(*current) {
+		return false, nil
+	}
+	channel, err := GetChannel(config)
+	if err != nil {
+		return false, err
+	}
+	if channel == PinnedChannel {
+		return true, nil
+	}
+	if !current.LessThan(*latest) {
+		return false, nil
+	}
+	if config.IgnitionMinVersion != "" {
+		minVersion, err := parseVersion(config.IgnitionMinVersion)
+		if err != nil {
+			return false, err
+		}
+		if latest.LessThan(*minVersion) {
+			return false, nil
+		}
+	}
+	if config.IgnitionMaxVersion != "" {
+		maxVersion, err := parseVersion(config.IgnitionMaxVersion)
+		if err != nil {
+			return false, err
+		}
+		if maxVersion.LessThan(*latest) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// resolveVersion returns the version the channel should be running
+func resolveVersion(config *runner.Config) (string, error) {
+	channel, err := GetChannel(config)
+	if err != nil {
+		return "", err
+	}
+	if channel == PinnedChannel {
+		return fmt.Sprintf("v%s", strings.TrimPrefix(config.PinnedVersion, "v")), nil
+	}
+	versionURL := config.IgnitionVersionURL
+	if versionURL == "" {
+		versionURL = DefaultVersionURL
+	}
+	resolveName := "latest"
+	if channel == BetaChannel {
+		resolveName = "beta"
+	}
+	return resolveLatestVersion(source.Expand(versionURL, "", source.Vars{
+		"channel": resolveName,
+	}))
+}
+
+func parseVersion(version string) (*semver.Version, error) {
+	return semver.NewVersion(strings.TrimPrefix(version, "v"))
+}
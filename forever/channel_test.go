@@ -0,0 +1,92 @@
+package forever_test
+
+import (
+	"github.com/octoblu/go-meshblu-connector-ignition/forever"
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Channel", func() {
+	var config *runner.Config
+
+	Describe("->GetChannel", func() {
+		It("should default to stable", func() {
+			channel, err := forever.GetChannel(&runner.Config{})
+			Expect(err).To(BeNil())
+			Expect(channel).To(Equal(forever.StableChannel))
+		})
+
+		It("should require a PinnedVersion when pinned", func() {
+			_, err := forever.GetChannel(&runner.Config{UpdateChannel: "pinned"})
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should reject unknown channels", func() {
+			_, err := forever.GetChannel(&runner.Config{UpdateChannel: "nightly"})
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("->ShouldUpdate", func() {
+		Describe("on the stable channel", func() {
+			BeforeEach(func() {
+				config = &runner.Config{}
+			})
+
+			It("should update to a newer version", func() {
+				Expect(forever.ShouldUpdate(config, "10.0.4", "v10.0.10")).To(BeTrue())
+			})
+
+			It("should not update to the same version", func() {
+				Expect(forever.ShouldUpdate(config, "10.0.4", "v10.0.4")).To(BeFalse())
+			})
+
+			It("should not downgrade", func() {
+				Expect(forever.ShouldUpdate(config, "10.0.4", "v9.9.9")).To(BeFalse())
+			})
+
+			It("should not update without a version", func() {
+				Expect(forever.ShouldUpdate(config, "10.0.4", "")).To(BeFalse())
+			})
+
+			It("should return an error for an invalid version", func() {
+				_, err := forever.ShouldUpdate(config, "10.0.4", "latest")
+				Expect(err).NotTo(BeNil())
+			})
+		})
+
+		Describe("with a version range", func() {
+			BeforeEach(func() {
+				config = &runner.Config{IgnitionMinVersion: "10.1.0", IgnitionMaxVersion: "v10.9.9"}
+			})
+
+			It("should update within the range", func() {
+				Expect(forever.ShouldUpdate(config, "10.0.4", "v10.2.0")).To(BeTrue())
+			})
+
+			It("should not update below the range", func() {
+				Expect(forever.ShouldUpdate(config, "10.0.4", "v10.0.5")).To(BeFalse())
+			})
+
+			It("should not update above the range", func() {
+				Expect(forever.ShouldUpdate(config, "10.0.4", "v11.0.0")).To(BeFalse())
+			})
+		})
+
+		Describe("on the pinned channel", func() {
+			BeforeEach(func() {
+				config = &runner.Config{UpdateChannel: "pinned", PinnedVersion: "9.0.0"}
+			})
+
+			It("should downgrade to the pinned version", func() {
+				Expect(forever.ShouldUpdate(config, "10.0.4", "v9.0.0")).To(BeTrue())
+			})
+
+			It("should not update when running the pinned version", func() {
+				Expect(forever.ShouldUpdate(config, "9.0.0", "v9.0.0")).To(BeFalse())
+			})
+		})
+	})
+})
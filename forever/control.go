@@ -0,0 +1,73 @@
+package forever
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/control"
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+)
+
+const defaultTailLines = 20
+
+type controlStatus struct {
+	IgnitionVersion string           `json:"ignitionVersion"`
+	Running         bool             `json:"running"`
+	Connectors      []*runner.Status `json:"connectors,omitempty"`
+}
+
+func (client *Client) listenForControl() control.Server {
+	controlServer, err := control.Listen(client.handleControl)
+	if err != nil {
+		mainLogger.Error("forever", "Error starting control server", err)
+		return nil
+	}
+	mainLogger.Info("forever", "control server listening")
+	return controlServer
+}
+
+func (client *Client) handleControl(request *control.Request) (string, error) {
+	mainLogger.Info("forever", fmt.Sprintf("control command %v", request.Command))
+	switch request.Command {
+	case control.StatusCommand:
+		return client.controlStatus()
+	case control.RestartCommand:
+		return "restarting connector", client.runnerClient.Restart(request.Connector)
+	case control.StopChildCommand:
+		return "stopping connector", client.runnerClient.StopChild(request.Connector)
+	case control.StartChildCommand:
+		return "starting connector", client.runnerClient.StartChild(request.Connector)
+	case control.ForceUpdateCommand:
+		if client.getServiceConfig().DisableSelfUpdate {
+			return "", fmt.Errorf("self update is disabled")
+		}
+		select {
+		case client.updateChan <- true:
+		default:
+		}
+		return "checking for ignition update", nil
+	case control.TailLogsCommand:
+		lines := request.Lines
+		if lines <= 0 {
+			lines = defaultTailLines
+		}
+		return client.runnerClient.TailLogs(request.Connector, lines)
+	}
+	return "", fmt.Errorf("unknown command: %v", request.Command)
+}
+
+func (client *Client) controlStatus() (string, error) {
+	status := &controlStatus{
+		IgnitionVersion: client.currentVersion,
+		Running:         client.running,
+	}
+	connectorStatuses, err := client.runnerClient.Status()
+	if err == nil {
+		status.Connectors = connectorStatuses
+	}
+	data, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,63 @@
+package forever
+
+import (
+	"os/exec"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+	"github.com/uber-go/atomic"
+)
+
+func init() {
+	mainLogger = logger.NewFakeMainLogger()
+}
+
+// UseExecutable makes the update files live next to the
+// executable path and returns a function restoring the default
+func UseExecutable(path string) func() {
+	oldExecutablePath := executablePath
+	executablePath = func() (string, error) {
+		return path, nil
+	}
+	return func() {
+		executablePath = oldExecutablePath
+	}
+}
+
+// NewUpdatingClient creates a client in the middle of an update
+func NewUpdatingClient(currentVersion string) *Client {
+	return &Client{currentVersion: currentVersion, updating: atomic.NewBool(true)}
+}
+
+// IsUpdating returns true while the client is updating
+func (client *Client) IsUpdating() bool {
+	return client.updating.Load()
+}
+
+// RollbackUpdate exposes rollbackUpdate
+func (client *Client) RollbackUpdate(version string, updateErr error) {
+	client.rollbackUpdate(version, updateErr)
+}
+
+// WaitForRunning exposes waitForRunning
+func WaitForRunning(cmd *exec.Cmd, deadline time.Duration) error {
+	return waitForRunning(cmd, deadline)
+}
+
+// StartIgnition exposes startIgnition
+var StartIgnition = startIgnition
+
+// RestoreOld exposes restoreOld
+var RestoreOld = restoreOld
+
+// IsBlacklisted exposes isBlacklisted
+var IsBlacklisted = isBlacklisted
+
+// BlacklistVersion exposes blacklistVersion
+var BlacklistVersion = blacklistVersion
+
+// GetPID exposes getPID
+var GetPID = getPID
+
+// GetRunningPID exposes getRunningPID
+var GetRunningPID = getRunningPID
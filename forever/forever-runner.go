@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+	"github.com/octoblu/go-meshblu-connector-ignition/pidlock"
 	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+	"github.com/uber-go/atomic"
 )
 
 var mainLogger logger.MainLogger
@@ -21,18 +24,28 @@ type Forever interface {
 
 // Client defines the stucture of the client
 type Client struct {
+	serviceConfig  *runner.Config
+	configMutex    sync.Mutex
 	runnerClient   runner.Runner
 	running        bool
 	currentVersion string
+	updating       *atomic.Bool
+	updateChan     chan bool
 }
 
+// runningDeadline is how long a new ignition has to confirm it is running
+const runningDeadline = 2 * time.Minute
+
 // NewRunner creates a new instance of the forever runner
 func NewRunner(serviceConfig *runner.Config, currentVersion string) Forever {
-	runnerClient := runner.New(serviceConfig)
+	runnerClient := runner.New(serviceConfig, currentVersion)
 	return &Client{
+		serviceConfig:  serviceConfig,
 		runnerClient:   runnerClient,
 		running:        false,
 		currentVersion: currentVersion,
+		updating:       atomic.NewBool(false),
+		updateChan:     make(chan bool, 1),
 	}
 }
 
@@ -42,15 +55,21 @@ func (client *Client) Start() error {
 		mainLogger = logger.GetMainLogger()
 	}
 	pid := os.Getpid()
-	err := writePID(pid)
+	lock, err := lockPID(pid)
 	if err != nil {
 		mainLogger.Error("forever", "Error locking", err)
 		return err
 	}
+	defer lock.Unlock()
 	mainLogger.Info("forever", fmt.Sprintf("locking pid %v", pid))
+	controlServer := client.listenForControl()
+	if controlServer != nil {
+		defer controlServer.Close()
+	}
 	client.waitForProcessChange()
 	client.waitForSigterm()
 	client.waitForUpdate()
+	client.waitForReload()
 	client.running = true
 	for {
 		if !client.running {
@@ -64,7 +83,14 @@ func (client *Client) Start() error {
 		}
 		mainLogger.Info("forever", "running...")
 		mainLogger.Info("forever", fmt.Sprintf("unlocking pid %v", pid))
-		unlockPID()
+		err = lock.Unlock()
+		if err != nil {
+			mainLogger.Error("forever", "Error unlocking", err)
+		}
+		err = writeRunning(pid)
+		if err != nil {
+			mainLogger.Error("forever", "Error confirming running", err)
+		}
 		for {
 			if !client.running {
 				mainLogger.Info("forever", "forever is over, shutting down")
@@ -82,6 +108,25 @@ func (client *Client) Shutdown() {
 	client.running = false
 }
 
+// lockPID takes the start lock, so only one ignition at a time
+// starts, and records the pid as the owner of the ignition
+func lockPID(pid int) (*pidlock.Lock, error) {
+	path, err := getLockPath()
+	if err != nil {
+		return nil, err
+	}
+	lock, err := pidlock.New(path, pid)
+	if err != nil {
+		return nil, err
+	}
+	err = writePID(pid)
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	return lock, nil
+}
+
 func (client *Client) waitForSigterm() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -94,36 +139,83 @@ func (client *Client) waitForSigterm() {
 	}()
 }
 
+// getServiceConfig returns the service config, reload replaces it
+func (client *Client) getServiceConfig() *runner.Config {
+	client.configMutex.Lock()
+	defer client.configMutex.Unlock()
+	return client.serviceConfig
+}
+
+// waitForUpdate checks for a new ignition version every minute,
+// the service config is read on each check so a reload can turn
+// the self update on or off
 func (client *Client) waitForUpdate() {
+	serviceConfig := client.getServiceConfig()
+	channel, err := GetChannel(serviceConfig)
+	if serviceConfig.DisableSelfUpdate {
+		mainLogger.Info("forever", "self update is disabled")
+	} else if err != nil {
+		mainLogger.Error("forever", "self update is disabled", err)
+	} else {
+		mainLogger.Info("forever", fmt.Sprintf("self update channel %v", channel))
+	}
 	go func() {
 		firstTime := true
 		for {
 			if !firstTime {
-				time.Sleep(time.Minute)
-				// startNew()
-				// return
+				select {
+				case <-time.After(time.Minute):
+				case <-client.updateChan:
+					mainLogger.Info("forever", "update check forced")
+				}
 			}
 			firstTime = false
-			latestVersion, err := resolveLatestVersion()
+			serviceConfig := client.getServiceConfig()
+			if serviceConfig.DisableSelfUpdate {
+				continue
+			}
+			latestVersion, err := resolveVersion(serviceConfig)
 			if err != nil {
 				mainLogger.Error("forever", "Cannot get latest version", err)
 				continue
 			}
-			if !shouldUpdate(client.currentVersion, latestVersion) {
+			needsUpdate, err := ShouldUpdate(serviceConfig, client.currentVersion, latestVersion)
+			if err != nil {
+				mainLogger.Error("forever", "Cannot compare versions", err)
+				continue
+			}
+			if !needsUpdate {
+				continue
+			}
+			blacklisted, err := isBlacklisted(latestVersion)
+			if err != nil {
+				mainLogger.Error("forever", "Cannot read blacklist", err)
+				continue
+			}
+			if blacklisted {
+				mainLogger.Info("forever", fmt.Sprintf("skipping blacklisted ignition version %s", latestVersion))
 				continue
 			}
 			mainLogger.Info("forever", fmt.Sprintf("there is a new ignition version %s", latestVersion))
-			err = doUpdate(latestVersion)
+			err = doUpdate(serviceConfig.IgnitionDownloadURL, latestVersion)
 			if err != nil {
 				mainLogger.Error("forever", "Error updating myself", err)
 				continue
 			}
-			err = startNew()
+			client.updating.Store(true)
+			cmd, err := startNew()
 			if err != nil {
-				mainLogger.Error("forever", "start new error", err)
+				client.rollbackUpdate(latestVersion, err)
 				continue
 			}
+			err = waitForRunning(cmd, runningDeadline)
+			if err != nil {
+				client.rollbackUpdate(latestVersion, err)
+				continue
+			}
+			client.updating.Store(false)
 			mainLogger.Info("forever", "I am updated and started new process")
+			client.Shutdown()
 			return
 		}
 	}()
@@ -144,9 +236,32 @@ func (client *Client) waitForProcessChange() {
 			if pid == os.Getpid() {
 				continue
 			}
+			if client.updating.Load() {
+				continue
+			}
 			mainLogger.Info("forever", fmt.Sprintf("process changed %v != %v", pid, os.Getpid()))
 			client.Shutdown()
 			return
 		}
 	}()
 }
+
+// rollbackUpdate restores the old binary and keeps running
+// when the new ignition did not start
+func (client *Client) rollbackUpdate(version string, updateErr error) {
+	mainLogger.Error("forever", fmt.Sprintf("new ignition %s failed, rolling back", version), updateErr)
+	err := restoreOld()
+	if err != nil {
+		mainLogger.Error("forever", "Error restoring old ignition", err)
+	}
+	err = blacklistVersion(version)
+	if err != nil {
+		mainLogger.Error("forever", "Error blacklisting version", err)
+	}
+	err = restorePID(os.Getpid())
+	if err != nil {
+		mainLogger.Error("forever", "Error restoring pid", err)
+	}
+	client.updating.Store(false)
+	mainLogger.Info("forever", fmt.Sprintf("rolled back to %v", client.currentVersion))
+}
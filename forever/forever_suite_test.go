@@ -0,0 +1,13 @@
+package forever_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestForever(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Forever Suite")
+}
@@ -0,0 +1,70 @@
+package forever
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+)
+
+// reloadInterval is how often service.json is checked for changes
+const reloadInterval = 5 * time.Second
+
+// waitForReload reloads service.json when it changes or on SIGHUP
+func (client *Client) waitForReload() {
+	path, err := runner.GetConfigPath()
+	if err != nil {
+		mainLogger.Error("forever", "config reload is disabled", err)
+		return
+	}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+
+	go func() {
+		lastModified := getModTime(path)
+		for {
+			select {
+			case <-time.After(reloadInterval):
+				modified := getModTime(path)
+				if modified.Equal(lastModified) {
+					continue
+				}
+				lastModified = modified
+				mainLogger.Info("forever", "service.json changed, reloading")
+			case <-sigChan:
+				lastModified = getModTime(path)
+				mainLogger.Info("forever", "SIGHUP received, reloading service.json")
+			}
+			client.reload()
+		}
+	}()
+}
+
+// reload applies the new service.json, keeping the
+// current config when it cannot be read or is invalid
+func (client *Client) reload() {
+	config, err := runner.GetConfig()
+	if err != nil {
+		mainLogger.Error("forever", "Error reading service.json, keeping the current config", err)
+		return
+	}
+	err = client.runnerClient.Reload(config)
+	if err != nil {
+		mainLogger.Error("forever", "Invalid service.json, keeping the current config", err)
+		return
+	}
+	client.configMutex.Lock()
+	client.serviceConfig = config
+	client.configMutex.Unlock()
+	mainLogger.Info("forever", "service.json reloaded")
+}
+
+func getModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -2,16 +2,15 @@ package forever
 
 import (
 	"encoding/json"
-	"fmt"
 	"path/filepath"
 
-	"github.com/kardianos/osext"
 	"github.com/spf13/afero"
 )
 
 type updateJSON struct {
-	PID    int  `json:"pid"`
-	Locked bool `json:"locked"`
+	PID        int      `json:"pid"`
+	RunningPID int      `json:"runningPid"`
+	Blacklist  []string `json:"blacklist,omitempty"`
 }
 
 func readConfig(path string) (*updateJSON, error) {
@@ -38,69 +37,135 @@ func readConfig(path string) (*updateJSON, error) {
 	return updateConfig, nil
 }
 
+// writePID records the pid as the owner of the ignition,
+// the process with another pid shuts down
 func writePID(pid int) error {
-	fs := afero.NewOsFs()
 	path, err := getUpdateConfigPath()
 	if err != nil {
 		return err
 	}
 	updateConfig, err := readConfig(path)
-	if updateConfig.Locked {
-		return fmt.Errorf("PID is locked! You must exit")
+	if err != nil {
+		return err
 	}
 	updateConfig.PID = pid
-	updateConfig.Locked = true
+	return writeConfig(path, updateConfig)
+}
+
+func getPID() (int, error) {
+	path, err := getUpdateConfigPath()
+	if err != nil {
+		return 0, err
+	}
+	updateConfig, err := readConfig(path)
+	if err != nil {
+		return 0, err
+	}
+	return updateConfig.PID, err
+}
+
+// writeConfig replaces the update.json at once,
+// so another ignition never reads it half written
+func writeConfig(path string, updateConfig *updateJSON) error {
+	fs := afero.NewOsFs()
 	jsonBytes, err := json.Marshal(updateConfig)
 	if err != nil {
 		return err
 	}
-	return afero.WriteFile(fs, path, jsonBytes, 0644)
+	tmpPath := path + ".tmp"
+	err = afero.WriteFile(fs, tmpPath, jsonBytes, 0644)
+	if err != nil {
+		return err
+	}
+	return fs.Rename(tmpPath, path)
 }
 
-func unlockPID() error {
-	fs := afero.NewOsFs()
+// writeRunning confirms the process acquired the lock and is running
+func writeRunning(pid int) error {
 	path, err := getUpdateConfigPath()
 	if err != nil {
 		return err
 	}
 	updateConfig, err := readConfig(path)
-	updateConfig.Locked = false
-	jsonBytes, err := json.Marshal(updateConfig)
 	if err != nil {
 		return err
 	}
-	return afero.WriteFile(fs, path, jsonBytes, 0644)
+	updateConfig.RunningPID = pid
+	return writeConfig(path, updateConfig)
 }
 
-func isLocked() (bool, error) {
+func getRunningPID() (int, error) {
 	path, err := getUpdateConfigPath()
 	if err != nil {
-		return false, err
+		return 0, err
 	}
 	updateConfig, err := readConfig(path)
 	if err != nil {
-		return false, err
+		return 0, err
 	}
-	return updateConfig.Locked, err
+	return updateConfig.RunningPID, nil
 }
 
-func getPID() (int, error) {
+// restorePID takes the pid back after a failed update
+func restorePID(pid int) error {
 	path, err := getUpdateConfigPath()
 	if err != nil {
-		return 0, err
+		return err
 	}
 	updateConfig, err := readConfig(path)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	return updateConfig.PID, err
+	updateConfig.PID = pid
+	updateConfig.RunningPID = pid
+	return writeConfig(path, updateConfig)
+}
+
+func blacklistVersion(version string) error {
+	path, err := getUpdateConfigPath()
+	if err != nil {
+		return err
+	}
+	updateConfig, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	updateConfig.Blacklist = append(updateConfig.Blacklist, version)
+	return writeConfig(path, updateConfig)
+}
+
+func isBlacklisted(version string) (bool, error) {
+	path, err := getUpdateConfigPath()
+	if err != nil {
+		return false, err
+	}
+	updateConfig, err := readConfig(path)
+	if err != nil {
+		return false, err
+	}
+	for _, blacklisted := range updateConfig.Blacklist {
+		if blacklisted == version {
+			return true, nil
+		}
+	}
+	return false, nil
 }
 
 func getUpdateConfigPath() (string, error) {
-	fullexecpath, err := osext.Executable()
+	fullexecpath, err := executablePath()
 	if err != nil {
 		return "", err
 	}
 	dir, _ := filepath.Split(fullexecpath)
 	return filepath.Join(dir, "update.json"), nil
 }
+
+// getLockPath returns the file locked while an ignition starts
+func getLockPath() (string, error) {
+	fullexecpath, err := executablePath()
+	if err != nil {
+		return "", err
+	}
+	dir, _ := filepath.Split(fullexecpath)
+	return filepath.Join(dir, "update.lock"), nil
+}
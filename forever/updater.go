@@ -4,34 +4,44 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"net/http"
+	"os"
 	"os/exec"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/inconshreveable/go-update"
 	"github.com/kardianos/osext"
+	"github.com/octoblu/go-meshblu-connector-ignition/source"
 )
 
+// DefaultDownloadURL is the template for ignition binaries
+const DefaultDownloadURL = "https://github.com/octoblu/go-meshblu-connector-ignition/releases/download/{tag}/{file}"
+
+// DefaultVersionURL resolves the latest ignition version of the {channel}
+const DefaultVersionURL = "https://connector-service.octoblu.com/releases/octoblu/go-meshblu-connector-ignition/{channel}/version/resolve"
+
+// executablePath returns the path of the running ignition,
+// the update files are kept next to it
+var executablePath = osext.Executable
+
 // VersionInfo defines the information of the request
 type VersionInfo struct {
 	Version string `json:"version"`
 }
 
-func shouldUpdate(currentVersion, latestVersion string) bool {
-	return fmt.Sprintf("v%s", currentVersion) != latestVersion
-}
-
-func doUpdate(version string) error {
-	downloadURL := getDownloadURL(version)
-	res, err := http.Get(downloadURL)
+func doUpdate(downloadURLTemplate, version string) error {
+	downloadURL := getDownloadURL(downloadURLTemplate, version)
+	body, err := source.Open(downloadURL)
 	if err != nil {
 		return err
 	}
-	if res.StatusCode != 200 {
-		return fmt.Errorf("Unexpected response status code %v", res.StatusCode)
+	defer body.Close()
+	oldPath, err := getOldPath()
+	if err != nil {
+		return err
 	}
-	defer res.Body.Close()
-	err = update.Apply(res.Body, update.Options{})
+	err = update.Apply(body, update.Options{OldSavePath: oldPath})
 	if err != nil {
 		if rerr := update.RollbackError(err); rerr != nil {
 			return rerr
@@ -41,23 +51,27 @@ func doUpdate(version string) error {
 	return nil
 }
 
-func getDownloadURL(version string) string {
-	baseURL := "https://github.com/octoblu/go-meshblu-connector-ignition/releases/download"
-	return fmt.Sprintf("%s/%s/meshblu-connector-ignition-%s-%s", baseURL, version, runtime.GOOS, runtime.GOARCH)
+func getDownloadURL(downloadURLTemplate, version string) string {
+	if downloadURLTemplate == "" {
+		downloadURLTemplate = DefaultDownloadURL
+	}
+	return source.Expand(downloadURLTemplate, "/{tag}/{file}", source.Vars{
+		"tag":     version,
+		"version": strings.TrimPrefix(version, "v"),
+		"file":    fmt.Sprintf("meshblu-connector-ignition-%s-%s", runtime.GOOS, runtime.GOARCH),
+		"os":      runtime.GOOS,
+		"arch":    runtime.GOARCH,
+	})
 }
 
-func resolveLatestVersion() (string, error) {
+func resolveLatestVersion(versionURL string) (string, error) {
 	var versionInfo VersionInfo
-	url := "https://connector-service.octoblu.com/releases/octoblu/go-meshblu-connector-ignition/latest/version/resolve"
-	res, err := http.Get(url)
+	res, err := source.Open(versionURL)
 	if err != nil {
 		return "", err
 	}
-	defer res.Body.Close()
-	if res.StatusCode != 200 {
-		return "", fmt.Errorf("Unexpected response status code %v", res.StatusCode)
-	}
-	body, err := ioutil.ReadAll(res.Body)
+	defer res.Close()
+	body, err := ioutil.ReadAll(res)
 	if err != nil {
 		return "", err
 	}
@@ -71,14 +85,65 @@ func resolveLatestVersion() (string, error) {
 	return versionInfo.Version, nil
 }
 
-func startNew() error {
-	ignitionScript, err := osext.Executable()
+func startNew() (*exec.Cmd, error) {
+	ignitionScript, err := executablePath()
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(ignitionScript, os.Args[1:]...)
+	err = startIgnition(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// waitForRunning waits for the new process to confirm it
+// acquired the pid lock and is running
+func waitForRunning(cmd *exec.Cmd, deadline time.Duration) error {
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	timeout := time.After(deadline)
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("new ignition exited before running: %v", err)
+		case <-timeout:
+			err := killIgnition(cmd)
+			if err != nil {
+				mainLogger.Error("forever", "Error killing the new ignition", err)
+			}
+			return fmt.Errorf("new ignition was not running after %v", deadline)
+		case <-ticker.C:
+			runningPID, err := getRunningPID()
+			if err == nil && runningPID == cmd.Process.Pid {
+				return nil
+			}
+		}
+	}
+}
+
+// restoreOld puts the binary replaced by doUpdate back
+func restoreOld() error {
+	ignitionScript, err := executablePath()
 	if err != nil {
 		return err
 	}
-	err = exec.Command(ignitionScript).Start()
+	oldPath, err := getOldPath()
 	if err != nil {
 		return err
 	}
-	return nil
+	return os.Rename(oldPath, ignitionScript)
+}
+
+func getOldPath() (string, error) {
+	ignitionScript, err := executablePath()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.old", ignitionScript), nil
 }
@@ -0,0 +1,25 @@
+// +build !windows
+
+package forever
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// startIgnition starts the new ignition in its own process
+// group, the connectors it starts join that group
+func startIgnition(cmd *exec.Cmd) error {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd.Start()
+}
+
+// killIgnition kills the process group of the new ignition,
+// so its connectors are not left running
+func killIgnition(cmd *exec.Cmd) error {
+	err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	if err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
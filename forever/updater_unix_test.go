@@ -0,0 +1,161 @@
+// +build !windows
+
+package forever_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/forever"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Updater", func() {
+	var dir, execPath string
+	var restore func()
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "forever")
+		Expect(err).NotTo(HaveOccurred())
+		execPath = filepath.Join(dir, "meshblu-connector-ignition")
+		Expect(ioutil.WriteFile(execPath, []byte("new"), 0755)).To(Succeed())
+		restore = forever.UseExecutable(execPath)
+	})
+
+	AfterEach(func() {
+		restore()
+		os.RemoveAll(dir)
+	})
+
+	Describe("->WaitForRunning", func() {
+		// fakeIgnition runs the shell script as the new ignition,
+		// $$ is the pid of the started command
+		fakeIgnition := func(script string) *exec.Cmd {
+			cmd := exec.Command("/bin/sh", "-c", script)
+			Expect(forever.StartIgnition(cmd)).To(Succeed())
+			return cmd
+		}
+
+		Describe("when the new ignition confirms it is running", func() {
+			It("should return", func() {
+				cmd := fakeIgnition(fmt.Sprintf(`echo "{\"runningPid\":$$}" > %s/update.json; exec sleep 10`, dir))
+				defer cmd.Process.Kill()
+				Expect(forever.WaitForRunning(cmd, 5*time.Second)).To(Succeed())
+			})
+		})
+
+		Describe("when the new ignition exits", func() {
+			It("should return an error", func() {
+				cmd := fakeIgnition("exit 3")
+				err := forever.WaitForRunning(cmd, 5*time.Second)
+				Expect(err).To(MatchError("new ignition exited before running: exit status 3"))
+			})
+		})
+
+		Describe("when the new ignition never confirms", func() {
+			It("should kill it and return an error", func() {
+				cmd := fakeIgnition("exec sleep 10")
+				err := forever.WaitForRunning(cmd, 100*time.Millisecond)
+				Expect(err).To(MatchError("new ignition was not running after 100ms"))
+				Eventually(func() error {
+					return syscall.Kill(cmd.Process.Pid, 0)
+				}).Should(HaveOccurred())
+			})
+		})
+
+		Describe("when the new ignition never confirms and started a connector", func() {
+			It("should kill the connector too", func() {
+				reader, writer, err := os.Pipe()
+				Expect(err).NotTo(HaveOccurred())
+				defer reader.Close()
+				cmd := exec.Command("/bin/sh", "-c", "sleep 10 & exec sleep 10")
+				cmd.Stdout = writer
+				Expect(forever.StartIgnition(cmd)).To(Succeed())
+				writer.Close()
+				Expect(forever.WaitForRunning(cmd, 100*time.Millisecond)).NotTo(Succeed())
+
+				// the pipe closes once every process holding it exited
+				closed := make(chan bool)
+				go func() {
+					ioutil.ReadAll(reader)
+					close(closed)
+				}()
+				Eventually(closed, 5*time.Second).Should(BeClosed())
+			})
+		})
+	})
+
+	Describe("->RestoreOld", func() {
+		It("should put the old binary back", func() {
+			Expect(ioutil.WriteFile(execPath+".old", []byte("old"), 0755)).To(Succeed())
+			Expect(forever.RestoreOld()).To(Succeed())
+			data, err := ioutil.ReadFile(execPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("old"))
+			_, err = os.Stat(execPath + ".old")
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		Describe("without an old binary", func() {
+			It("should keep the binary and return an error", func() {
+				Expect(forever.RestoreOld()).NotTo(Succeed())
+				data, err := ioutil.ReadFile(execPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(data)).To(Equal("new"))
+			})
+		})
+	})
+
+	Describe("the blacklist", func() {
+		It("should not have a version at first", func() {
+			Expect(forever.IsBlacklisted("v10.0.1")).To(BeFalse())
+		})
+
+		It("should have the blacklisted versions", func() {
+			Expect(forever.BlacklistVersion("v10.0.1")).To(Succeed())
+			Expect(forever.BlacklistVersion("v10.0.2")).To(Succeed())
+			Expect(forever.IsBlacklisted("v10.0.1")).To(BeTrue())
+			Expect(forever.IsBlacklisted("v10.0.2")).To(BeTrue())
+			Expect(forever.IsBlacklisted("v10.0.3")).To(BeFalse())
+		})
+	})
+
+	Describe("->RollbackUpdate", func() {
+		var client *forever.Client
+
+		BeforeEach(func() {
+			Expect(ioutil.WriteFile(execPath+".old", []byte("old"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(dir, "update.json"), []byte(`{"pid":12345,"runningPid":12345}`), 0644)).To(Succeed())
+			client = forever.NewUpdatingClient("v10.0.0")
+			client.RollbackUpdate("v10.0.1", errors.New("oh no"))
+		})
+
+		It("should restore the old binary", func() {
+			data, err := ioutil.ReadFile(execPath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("old"))
+		})
+
+		It("should blacklist the version", func() {
+			Expect(forever.IsBlacklisted("v10.0.1")).To(BeTrue())
+		})
+
+		It("should take the pid back", func() {
+			Expect(forever.GetPID()).To(Equal(os.Getpid()))
+			Expect(forever.GetRunningPID()).To(Equal(os.Getpid()))
+		})
+
+		It("should no longer be updating", func() {
+			Expect(client.IsUpdating()).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,21 @@
+package forever
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// startIgnition starts the new ignition
+func startIgnition(cmd *exec.Cmd) error {
+	return cmd.Start()
+}
+
+// killIgnition kills the process tree of the new ignition,
+// so its connectors are not left running
+func killIgnition(cmd *exec.Cmd) error {
+	err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+	if err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package health
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Checker defines the interface of a liveness check
+type Checker interface {
+	Check() error
+}
+
+// HTTP checks that an endpoint responds with a non error status code
+type HTTP struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewHTTP creates a check requesting the url
+func NewHTTP(url string, timeout time.Duration) Checker {
+	return &HTTP{url, &http.Client{Timeout: timeout}}
+}
+
+// Check requests the url
+func (check *HTTP) Check() error {
+	response, err := check.httpClient.Get(check.url)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode > 399 {
+		return fmt.Errorf("%s returned invalid response code: %v", check.url, response.StatusCode)
+	}
+	return nil
+}
+
+// TCP checks that a port accepts connections
+type TCP struct {
+	address string
+	timeout time.Duration
+}
+
+// NewTCP creates a check connecting to the host:port address
+func NewTCP(address string, timeout time.Duration) Checker {
+	return &TCP{address, timeout}
+}
+
+// Check connects to the address
+func (check *TCP) Check() error {
+	conn, err := net.DialTimeout("tcp", check.address, check.timeout)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// Heartbeat checks that the modified time of a file advances
+type Heartbeat struct {
+	path         string
+	lastModified time.Time
+	mutex        sync.Mutex
+}
+
+// NewHeartbeat creates a check of the file
+func NewHeartbeat(path string) Checker {
+	return &Heartbeat{path: path}
+}
+
+// Check compares the modified time of the file with the last check
+func (check *Heartbeat) Check() error {
+	info, err := os.Stat(check.path)
+	if err != nil {
+		return err
+	}
+	check.mutex.Lock()
+	defer check.mutex.Unlock()
+	modified := info.ModTime()
+	if !modified.After(check.lastModified) {
+		return fmt.Errorf("%s was not modified since %v", check.path, check.lastModified)
+	}
+	check.lastModified = modified
+	return nil
+}
+
+// Command checks that a command exits with code 0
+type Command struct {
+	dir, command string
+	args         []string
+	timeout      time.Duration
+}
+
+// NewCommand creates a check running the command in the dir
+func NewCommand(dir, command string, args []string, timeout time.Duration) Checker {
+	return &Command{dir, command, args, timeout}
+}
+
+// Check runs the command, killing it after the timeout
+func (check *Command) Check() error {
+	var output bytes.Buffer
+	cmd := exec.Command(check.command, check.args...)
+	cmd.Dir = check.dir
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Start()
+	if err != nil {
+		return fmt.Errorf("%s failed: %v", check.command, err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	timer := time.NewTimer(check.timeout)
+	defer timer.Stop()
+	select {
+	case err = <-done:
+	case <-timer.C:
+		// the output is not waited for, a child of
+		// the command may still hold it open
+		cmd.Process.Kill()
+		return fmt.Errorf("%s timed out after %v", check.command, check.timeout)
+	}
+	if err != nil {
+		return fmt.Errorf("%s failed: %v %s", check.command, err, output.String())
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package health_test
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/health"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Health", func() {
+	Describe("->NewHTTP", func() {
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/healthcheck" {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should pass when the endpoint responds", func() {
+			check := health.NewHTTP(server.URL+"/healthcheck", time.Second)
+			Expect(check.Check()).To(Succeed())
+		})
+
+		It("should fail when the endpoint returns an error", func() {
+			check := health.NewHTTP(server.URL+"/other", time.Second)
+			Expect(check.Check()).NotTo(Succeed())
+		})
+	})
+
+	Describe("->NewTCP", func() {
+		It("should pass when the port is listening", func() {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).To(BeNil())
+			defer listener.Close()
+			check := health.NewTCP(listener.Addr().String(), time.Second)
+			Expect(check.Check()).To(Succeed())
+		})
+
+		It("should fail when the port is closed", func() {
+			listener, _ := net.Listen("tcp", "127.0.0.1:0")
+			address := listener.Addr().String()
+			listener.Close()
+			check := health.NewTCP(address, time.Second)
+			Expect(check.Check()).NotTo(Succeed())
+		})
+	})
+
+	Describe("->NewHeartbeat", func() {
+		var dir, path string
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "health-test")
+			path = filepath.Join(dir, "heartbeat")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should fail when the file is missing", func() {
+			check := health.NewHeartbeat(path)
+			Expect(check.Check()).NotTo(Succeed())
+		})
+
+		It("should fail unless the file is modified between checks", func() {
+			ioutil.WriteFile(path, []byte(""), 0644)
+			check := health.NewHeartbeat(path)
+			Expect(check.Check()).To(Succeed())
+			Expect(check.Check()).NotTo(Succeed())
+
+			later := time.Now().Add(time.Minute)
+			os.Chtimes(path, later, later)
+			Expect(check.Check()).To(Succeed())
+		})
+	})
+
+	Describe("->NewCommand", func() {
+		It("should pass when the command exits with code 0", func() {
+			check := health.NewCommand("", "sh", []string{"-c", "exit 0"}, time.Second)
+			Expect(check.Check()).To(Succeed())
+		})
+
+		It("should fail when the command exits with an error", func() {
+			check := health.NewCommand("", "sh", []string{"-c", "exit 1"}, time.Second)
+			Expect(check.Check()).NotTo(Succeed())
+		})
+
+		It("should fail when the command times out", func() {
+			check := health.NewCommand("", "sh", []string{"-c", "sleep 5"}, 100*time.Millisecond)
+			Expect(check.Check()).To(MatchError(ContainSubstring("timed out")))
+		})
+	})
+})
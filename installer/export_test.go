@@ -0,0 +1,32 @@
+package installer
+
+import "github.com/octoblu/go-meshblu-connector-ignition/runner"
+
+// UseSystemd points the systemd functions at the unit dir and
+// systemctl command and returns a function restoring the defaults
+func UseSystemd(unitDir, systemctl string) func() {
+	oldUnitDir, oldSystemctl := systemdUnitDir, systemctlCommand
+	systemdUnitDir, systemctlCommand = unitDir, systemctl
+	return func() {
+		systemdUnitDir, systemctlCommand = oldUnitDir, oldSystemctl
+	}
+}
+
+// NewSystemdClient creates a client for the execPath, without
+// asking the service manager
+func NewSystemdClient(config *runner.Config, execPath string) *Client {
+	return &Client{config: config, execPath: execPath}
+}
+
+// InstallSystemd exposes installSystemd
+func (client *Client) InstallSystemd() error {
+	return client.installSystemd()
+}
+
+// UninstallSystemd exposes uninstallSystemd
+func (client *Client) UninstallSystemd() error {
+	return client.uninstallSystemd()
+}
+
+// SystemdStatus exposes systemdStatus
+var SystemdStatus = systemdStatus
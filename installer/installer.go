@@ -0,0 +1,104 @@
+package installer
+
+import (
+	"github.com/kardianos/osext"
+	"github.com/kardianos/service"
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+)
+
+// States of the installed service
+const (
+	StatusRunning      = "running"
+	StatusStopped      = "stopped"
+	StatusNotInstalled = "not installed"
+)
+
+// Installer registers and controls ignition as a service of the OS
+type Installer interface {
+	Install() error
+	Uninstall() error
+	Start() error
+	Stop() error
+	Restart() error
+	Status() (string, error)
+}
+
+// Client defines the structure of the installer
+type Client struct {
+	config   *runner.Config
+	srv      service.Service
+	execPath string
+}
+
+// New creates an installer for the service of the config,
+// running the current ignition executable
+func New(config *runner.Config) (Installer, error) {
+	execPath, err := osext.Executable()
+	if err != nil {
+		return nil, err
+	}
+	srv, err := service.New(&program{}, &service.Config{
+		Name:        config.ServiceName,
+		DisplayName: config.DisplayName,
+		Description: config.Description,
+		Executable:  execPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Client{config: config, srv: srv, execPath: execPath}, nil
+}
+
+// Install registers the service, writing the systemd
+// unit itself when systemd is the service manager
+func (client *Client) Install() error {
+	if isSystemd() {
+		return client.installSystemd()
+	}
+	return service.Control(client.srv, "install")
+}
+
+// Uninstall removes the service
+func (client *Client) Uninstall() error {
+	if isSystemd() {
+		return client.uninstallSystemd()
+	}
+	return service.Control(client.srv, "uninstall")
+}
+
+// Start starts the installed service
+func (client *Client) Start() error {
+	return service.Control(client.srv, "start")
+}
+
+// Stop stops the installed service
+func (client *Client) Stop() error {
+	return service.Control(client.srv, "stop")
+}
+
+// Restart restarts the installed service
+func (client *Client) Restart() error {
+	return service.Control(client.srv, "restart")
+}
+
+// Status returns the state of the service in the service manager
+func (client *Client) Status() (string, error) {
+	return getStatus(client.config.ServiceName)
+}
+
+// isSystemd returns true if systemd is the service manager
+func isSystemd() bool {
+	system := service.ChosenSystem()
+	return system != nil && system.String() == "linux-systemd"
+}
+
+// program is never run, the service is only controlled
+type program struct{}
+
+func (*program) Start(_ service.Service) error {
+	return nil
+}
+
+func (*program) Stop(_ service.Service) error {
+	return nil
+}
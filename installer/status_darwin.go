@@ -0,0 +1,18 @@
+package installer
+
+import (
+	"os/exec"
+	"strings"
+)
+
+func getStatus(serviceName string) (string, error) {
+	// launchctl only lists the loaded services
+	output, err := exec.Command("launchctl", "list", serviceName).Output()
+	if err != nil {
+		return StatusNotInstalled, nil
+	}
+	if strings.Contains(string(output), `"PID" =`) {
+		return StatusRunning, nil
+	}
+	return StatusStopped, nil
+}
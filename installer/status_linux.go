@@ -0,0 +1,14 @@
+package installer
+
+import (
+	"fmt"
+
+	"github.com/kardianos/service"
+)
+
+func getStatus(serviceName string) (string, error) {
+	if isSystemd() {
+		return systemdStatus(serviceName)
+	}
+	return "", fmt.Errorf("status is not supported by %v", service.ChosenSystem())
+}
@@ -0,0 +1,34 @@
+package installer
+
+import (
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+func getStatus(serviceName string) (string, error) {
+	manager, err := mgr.Connect()
+	if err != nil {
+		return "", err
+	}
+	defer manager.Disconnect()
+	srv, err := manager.OpenService(serviceName)
+	if err != nil {
+		return StatusNotInstalled, nil
+	}
+	defer srv.Close()
+	status, err := srv.Query()
+	if err != nil {
+		return "", err
+	}
+	switch status.State {
+	case svc.Running:
+		return StatusRunning, nil
+	case svc.Stopped:
+		return StatusStopped, nil
+	case svc.StartPending:
+		return "starting", nil
+	case svc.StopPending:
+		return "stopping", nil
+	}
+	return "paused", nil
+}
@@ -0,0 +1,171 @@
+package installer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+)
+
+// systemdUnitDir is where the systemd units are installed
+var systemdUnitDir = "/etc/systemd/system"
+
+// systemctlCommand controls the systemd units
+var systemctlCommand = "systemctl"
+
+// SystemdUnitPath returns the path of the unit file of the service
+func SystemdUnitPath(serviceName string) string {
+	return path.Join(systemdUnitDir, serviceName+".service")
+}
+
+// SystemdUnit returns the systemd unit running the ignition
+// executable with the Systemd options of the config
+func SystemdUnit(config *runner.Config, execPath string) (string, error) {
+	systemd := &config.Systemd
+	err := systemd.Validate()
+	if err != nil {
+		return "", err
+	}
+	restart, err := systemd.GetRestart()
+	if err != nil {
+		return "", err
+	}
+	description := config.Description
+	if description == "" {
+		description = config.DisplayName
+	}
+	if description == "" {
+		description = config.ServiceName
+	}
+	if strings.ContainsAny(description, "\r\n") {
+		return "", fmt.Errorf("the description %q must not contain a line break", description)
+	}
+
+	var unit bytes.Buffer
+	fmt.Fprintln(&unit, "[Unit]")
+	fmt.Fprintf(&unit, "Description=%s\n", escapeSpecifiers(description))
+	fmt.Fprintf(&unit, "After=%s\n", strings.Join(systemd.GetAfter(), " "))
+	if wants := systemd.GetWants(); len(wants) > 0 {
+		fmt.Fprintf(&unit, "Wants=%s\n", strings.Join(wants, " "))
+	}
+	if len(systemd.Requires) > 0 {
+		fmt.Fprintf(&unit, "Requires=%s\n", strings.Join(systemd.Requires, " "))
+	}
+	fmt.Fprintln(&unit)
+	fmt.Fprintln(&unit, "[Service]")
+	fmt.Fprintf(&unit, "ExecStart=%s\n", quoteSystemd(escapeVariables(escapeSpecifiers(execPath))))
+	fmt.Fprintln(&unit, "ExecReload=/bin/kill -HUP $MAINPID")
+	fmt.Fprintf(&unit, "WorkingDirectory=%s\n", escapeSpecifiers(path.Dir(execPath)))
+	fmt.Fprintf(&unit, "Restart=%s\n", restart)
+	fmt.Fprintf(&unit, "RestartSec=%s\n", formatSystemdDuration(systemd.GetRestartSec()))
+	fmt.Fprintln(&unit, "KillMode=mixed")
+	// the ignition creates the cgroups of the connectors with limits
+	fmt.Fprintln(&unit, "Delegate=yes")
+	if systemd.User != "" {
+		fmt.Fprintf(&unit, "User=%s\n", systemd.User)
+	}
+	if systemd.Group != "" {
+		fmt.Fprintf(&unit, "Group=%s\n", systemd.Group)
+	}
+	fmt.Fprintln(&unit)
+	fmt.Fprintln(&unit, "[Install]")
+	fmt.Fprintf(&unit, "WantedBy=%s\n", systemd.GetWantedBy())
+	return unit.String(), nil
+}
+
+// installSystemd writes and enables the systemd unit
+func (client *Client) installSystemd() error {
+	serviceName := client.config.ServiceName
+	unit, err := SystemdUnit(client.config, client.execPath)
+	if err != nil {
+		return err
+	}
+	unitPath := SystemdUnitPath(serviceName)
+	_, err = os.Stat(unitPath)
+	if err == nil {
+		return fmt.Errorf("%s is already installed at %s", serviceName, unitPath)
+	}
+	err = ioutil.WriteFile(unitPath, []byte(unit), 0644)
+	if err != nil {
+		return err
+	}
+	err = systemctl("daemon-reload")
+	if err != nil {
+		return err
+	}
+	return systemctl("enable", serviceName+".service")
+}
+
+// uninstallSystemd disables and removes the systemd unit
+func (client *Client) uninstallSystemd() error {
+	serviceName := client.config.ServiceName
+	err := systemctl("disable", serviceName+".service")
+	if err != nil {
+		return err
+	}
+	err = os.Remove(SystemdUnitPath(serviceName))
+	if err != nil {
+		return err
+	}
+	return systemctl("daemon-reload")
+}
+
+// systemdStatus returns the state of the systemd unit
+func systemdStatus(serviceName string) (string, error) {
+	_, err := os.Stat(SystemdUnitPath(serviceName))
+	if os.IsNotExist(err) {
+		return StatusNotInstalled, nil
+	}
+	// is-active exits non zero unless active, the state is still printed
+	output, _ := exec.Command(systemctlCommand, "is-active", serviceName+".service").Output()
+	state := strings.TrimSpace(string(output))
+	switch state {
+	case "":
+		return "", fmt.Errorf("could not get the state of %s", serviceName)
+	case "active":
+		return StatusRunning, nil
+	case "inactive":
+		return StatusStopped, nil
+	}
+	return state, nil
+}
+
+func systemctl(args ...string) error {
+	output, err := exec.Command(systemctlCommand, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s: %v %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// quoteSystemd quotes the value when it has spaces or quotes
+func quoteSystemd(value string) string {
+	if !strings.ContainsAny(value, " \t\"\\") {
+		return value
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+// escapeSpecifiers keeps systemd from expanding % specifiers like %h
+func escapeSpecifiers(value string) string {
+	return strings.Replace(value, "%", "%%", -1)
+}
+
+// escapeVariables keeps systemd from expanding $VAR in the Exec lines
+func escapeVariables(value string) string {
+	return strings.Replace(value, "$", "$$", -1)
+}
+
+func formatSystemdDuration(duration time.Duration) string {
+	if duration%time.Second == 0 {
+		return fmt.Sprintf("%ds", duration/time.Second)
+	}
+	return fmt.Sprintf("%dms", duration/time.Millisecond)
+}
@@ -0,0 +1,264 @@
+package installer_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/installer"
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SystemdUnit", func() {
+	var config *runner.Config
+
+	BeforeEach(func() {
+		config = &runner.Config{
+			ServiceName: "MeshbluConnector-some-uuid",
+			DisplayName: "Say Hello",
+			Description: "Meshblu Connector Say Hello",
+		}
+	})
+
+	Describe("with the default options", func() {
+		It("should write the unit", func() {
+			unit, err := installer.SystemdUnit(config, "/opt/connectors/some-uuid/meshblu-connector-ignition")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(unit).To(Equal(`[Unit]
+Description=Meshblu Connector Say Hello
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+ExecStart=/opt/connectors/some-uuid/meshblu-connector-ignition
+ExecReload=/bin/kill -HUP $MAINPID
+WorkingDirectory=/opt/connectors/some-uuid
+Restart=always
+RestartSec=5s
+KillMode=mixed
+Delegate=yes
+
+[Install]
+WantedBy=multi-user.target
+`))
+		})
+	})
+
+	Describe("with the systemd options", func() {
+		It("should write the unit", func() {
+			config.Systemd = runner.Systemd{
+				Restart:    "on-failure",
+				RestartSec: runner.Duration(1500 * time.Millisecond),
+				After:      []string{"network-online.target", "docker.service"},
+				Wants:      []string{"network-online.target"},
+				Requires:   []string{"docker.service"},
+				User:       "connector",
+				Group:      "connectors",
+				WantedBy:   "default.target",
+			}
+			unit, err := installer.SystemdUnit(config, "/opt/meshblu connectors/meshblu-connector-ignition")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(unit).To(Equal(`[Unit]
+Description=Meshblu Connector Say Hello
+After=network-online.target docker.service
+Wants=network-online.target
+Requires=docker.service
+
+[Service]
+ExecStart="/opt/meshblu connectors/meshblu-connector-ignition"
+ExecReload=/bin/kill -HUP $MAINPID
+WorkingDirectory=/opt/meshblu connectors
+Restart=on-failure
+RestartSec=1500ms
+KillMode=mixed
+Delegate=yes
+User=connector
+Group=connectors
+
+[Install]
+WantedBy=default.target
+`))
+		})
+	})
+
+	Describe("with specifiers and variables in the path", func() {
+		It("should escape them", func() {
+			config.Description = "Meshblu Connector 100%"
+			unit, err := installer.SystemdUnit(config, "/opt/%h/$HOME connectors/meshblu-connector-ignition")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(unit).To(ContainSubstring("Description=Meshblu Connector 100%%\n"))
+			Expect(unit).To(ContainSubstring(`ExecStart="/opt/%%h/$$HOME connectors/meshblu-connector-ignition"` + "\n"))
+			Expect(unit).To(ContainSubstring("WorkingDirectory=/opt/%%h/$HOME connectors\n"))
+		})
+	})
+
+	Describe("with an invalid restart", func() {
+		It("should return an error", func() {
+			config.Systemd.Restart = "sometimes"
+			_, err := installer.SystemdUnit(config, "/opt/connectors/some-uuid/meshblu-connector-ignition")
+			Expect(err).To(MatchError(ContainSubstring("invalid systemd restart sometimes")))
+		})
+	})
+
+	Describe("with a line break in a value", func() {
+		It("should return an error", func() {
+			config.Systemd.After = []string{"network-online.target\nExecStartPre=/bin/sh"}
+			_, err := installer.SystemdUnit(config, "/opt/connectors/some-uuid/meshblu-connector-ignition")
+			Expect(err).To(MatchError(ContainSubstring("After")))
+			Expect(err).To(MatchError(ContainSubstring("must not contain a line break")))
+		})
+	})
+
+	Describe("with a line break in the description", func() {
+		It("should return an error", func() {
+			config.Description = "Say Hello\n[Service]"
+			_, err := installer.SystemdUnit(config, "/opt/connectors/some-uuid/meshblu-connector-ignition")
+			Expect(err).To(MatchError(ContainSubstring("must not contain a line break")))
+		})
+	})
+
+	Describe("when it does not start after the network-online.target", func() {
+		It("should not want it", func() {
+			config.Systemd.After = []string{"docker.service"}
+			unit, err := installer.SystemdUnit(config, "/opt/connectors/some-uuid/meshblu-connector-ignition")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(unit).NotTo(ContainSubstring("Wants="))
+		})
+	})
+})
+
+var _ = Describe("Systemd", func() {
+	var config *runner.Config
+	var tempDir, unitDir, callsPath string
+	var restore func()
+
+	writeSystemctl := func(state string, exitCode int) {
+		script := fmt.Sprintf("#!/bin/sh\necho \"$@\" >> %s\necho %s\nexit %d\n", callsPath, state, exitCode)
+		systemctlPath := filepath.Join(tempDir, "systemctl")
+		Expect(ioutil.WriteFile(systemctlPath, []byte(script), 0755)).To(Succeed())
+	}
+
+	calls := func() []string {
+		data, err := ioutil.ReadFile(callsPath)
+		if os.IsNotExist(err) {
+			return []string{}
+		}
+		Expect(err).NotTo(HaveOccurred())
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	BeforeEach(func() {
+		var err error
+		tempDir, err = ioutil.TempDir("", "installer-test")
+		Expect(err).NotTo(HaveOccurred())
+		unitDir = filepath.Join(tempDir, "system")
+		Expect(os.Mkdir(unitDir, 0755)).To(Succeed())
+		callsPath = filepath.Join(tempDir, "calls")
+		writeSystemctl("active", 0)
+		restore = installer.UseSystemd(unitDir, filepath.Join(tempDir, "systemctl"))
+		config = &runner.Config{ServiceName: "MeshbluConnector-some-uuid"}
+	})
+
+	AfterEach(func() {
+		restore()
+		os.RemoveAll(tempDir)
+	})
+
+	Describe("->InstallSystemd", func() {
+		It("should write the unit, reload and enable it", func() {
+			client := installer.NewSystemdClient(config, "/opt/connectors/some-uuid/meshblu-connector-ignition")
+			Expect(client.InstallSystemd()).To(Succeed())
+
+			unit, err := ioutil.ReadFile(filepath.Join(unitDir, "MeshbluConnector-some-uuid.service"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(unit)).To(ContainSubstring("ExecStart=/opt/connectors/some-uuid/meshblu-connector-ignition\n"))
+			Expect(calls()).To(Equal([]string{"daemon-reload", "enable MeshbluConnector-some-uuid.service"}))
+		})
+
+		Describe("when the unit is already installed", func() {
+			It("should not overwrite it", func() {
+				unitPath := filepath.Join(unitDir, "MeshbluConnector-some-uuid.service")
+				Expect(ioutil.WriteFile(unitPath, []byte("mine"), 0644)).To(Succeed())
+
+				client := installer.NewSystemdClient(config, "/opt/connectors/some-uuid/meshblu-connector-ignition")
+				Expect(client.InstallSystemd()).To(MatchError(ContainSubstring("is already installed")))
+
+				unit, err := ioutil.ReadFile(unitPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(unit)).To(Equal("mine"))
+				Expect(calls()).To(BeEmpty())
+			})
+		})
+
+		Describe("when systemctl fails", func() {
+			It("should return its output", func() {
+				writeSystemctl("Access denied", 1)
+				client := installer.NewSystemdClient(config, "/opt/connectors/some-uuid/meshblu-connector-ignition")
+				Expect(client.InstallSystemd()).To(MatchError(ContainSubstring("systemctl daemon-reload: exit status 1 Access denied")))
+			})
+		})
+	})
+
+	Describe("->UninstallSystemd", func() {
+		It("should disable the unit, remove it and reload", func() {
+			unitPath := filepath.Join(unitDir, "MeshbluConnector-some-uuid.service")
+			Expect(ioutil.WriteFile(unitPath, []byte("unit"), 0644)).To(Succeed())
+
+			client := installer.NewSystemdClient(config, "/opt/connectors/some-uuid/meshblu-connector-ignition")
+			Expect(client.UninstallSystemd()).To(Succeed())
+
+			_, err := os.Stat(unitPath)
+			Expect(os.IsNotExist(err)).To(BeTrue())
+			Expect(calls()).To(Equal([]string{"disable MeshbluConnector-some-uuid.service", "daemon-reload"}))
+		})
+	})
+
+	Describe("SystemdStatus", func() {
+		BeforeEach(func() {
+			unitPath := filepath.Join(unitDir, "MeshbluConnector-some-uuid.service")
+			Expect(ioutil.WriteFile(unitPath, []byte("unit"), 0644)).To(Succeed())
+		})
+
+		Describe("when the unit is not installed", func() {
+			It("should be not installed", func() {
+				Expect(installer.SystemdStatus("MeshbluConnector-other-uuid")).To(Equal(installer.StatusNotInstalled))
+				Expect(calls()).To(BeEmpty())
+			})
+		})
+
+		Describe("when the unit is active", func() {
+			It("should be running", func() {
+				Expect(installer.SystemdStatus("MeshbluConnector-some-uuid")).To(Equal(installer.StatusRunning))
+				Expect(calls()).To(Equal([]string{"is-active MeshbluConnector-some-uuid.service"}))
+			})
+		})
+
+		Describe("when the unit is inactive", func() {
+			It("should be stopped", func() {
+				writeSystemctl("inactive", 3)
+				Expect(installer.SystemdStatus("MeshbluConnector-some-uuid")).To(Equal(installer.StatusStopped))
+			})
+		})
+
+		Describe("when the unit failed", func() {
+			It("should return the systemd state", func() {
+				writeSystemctl("failed", 3)
+				Expect(installer.SystemdStatus("MeshbluConnector-some-uuid")).To(Equal("failed"))
+			})
+		})
+
+		Describe("when systemctl prints nothing", func() {
+			It("should return an error", func() {
+				writeSystemctl("", 1)
+				_, err := installer.SystemdStatus("MeshbluConnector-some-uuid")
+				Expect(err).To(MatchError("could not get the state of MeshbluConnector-some-uuid"))
+			})
+		})
+	})
+})
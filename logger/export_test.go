@@ -0,0 +1,29 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// NewTestMainLogger creates a main logger writing to the stream
+func NewTestMainLogger(stream io.Writer, format string) MainLogger {
+	return &MainClient{
+		fileStream:     stream,
+		stderr:         ioutil.Discard,
+		currentVersion: "1.2.3",
+		format:         format,
+	}
+}
+
+// FailRenames makes the rotations fail to move the file to
+// its backup, until the returned func is called
+func FailRenames() func() {
+	renameFile = func(oldpath, newpath string) error {
+		return errors.New("rename failed")
+	}
+	return func() {
+		renameFile = os.Rename
+	}
+}
@@ -9,6 +9,8 @@ type FakeMainLogger interface {
 	Close() error
 	Info(key, msg string)
 	Error(key, msg string, err error)
+	InfoWithFields(key, msg string, fields Fields)
+	ErrorWithFields(key, msg string, err error, fields Fields)
 }
 
 // NewFakeMainLogger creates a fake instance of the logger
@@ -24,6 +26,14 @@ func (client *fakeMainLoggerClient) Info(key, msg string) {
 func (client *fakeMainLoggerClient) Error(key, msg string, err error) {
 }
 
+// InfoWithFields log a message with structured fields
+func (client *fakeMainLoggerClient) InfoWithFields(key, msg string, fields Fields) {
+}
+
+// ErrorWithFields log a message with structured fields
+func (client *fakeMainLoggerClient) ErrorWithFields(key, msg string, err error, fields Fields) {
+}
+
 // Clear the stream
 func (client *fakeMainLoggerClient) Clear() error {
 	return nil
@@ -1,16 +1,16 @@
 package logger
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"time"
 )
 
 // Streams defines the streams supported by the logger
 type Streams struct {
-	memory *bytes.Buffer
-	file   *os.File
+	memory *ringBuffer
+	file   *rotatingFile
 }
 
 // Client defines the logger struct
@@ -19,6 +19,30 @@ type Client struct {
 	isErrorStream bool
 }
 
+// Options defines the rotation and retention of the log
+type Options struct {
+	// MaxSize is the size in bytes before the file is rotated, defaults to 10MB
+	MaxSize int64
+
+	// RotateEvery is the age of the file before it is rotated, disabled when 0
+	RotateEvery time.Duration
+
+	// MaxBackups is the number of rotated files retained, defaults to 5
+	MaxBackups int
+
+	// MaxAge is the age of rotated files before they are removed, disabled when 0
+	MaxAge time.Duration
+
+	// Compress gzips the rotated files
+	Compress bool
+
+	// MemoryBytes is the size of the in-memory capture, defaults to 64KB
+	MemoryBytes int
+
+	// MemoryLines is the number of lines in the in-memory capture, defaults to 1000
+	MemoryLines int
+}
+
 // Logger defines the interface for logging to mult-streams
 type Logger interface {
 	Stream() io.Writer
@@ -27,18 +51,19 @@ type Logger interface {
 	Close() error
 }
 
-// NewLogger creates an instance of a logger
-func NewLogger(filePath string, isErrorStream bool) (Logger, error) {
+// NewLogger creates an instance of a logger, options may be nil
+func NewLogger(filePath string, isErrorStream bool, options *Options) (Logger, error) {
 	if filePath == "" {
 		return nil, fmt.Errorf("Missing Log File Path %v", filePath)
 	}
+	options = withDefaults(options)
 	streams := &Streams{}
-	file, err := getFileFromPath(filePath)
+	file, err := openRotatingFile(filePath, options)
 	if err != nil {
 		return nil, err
 	}
 	streams.file = file
-	streams.memory = memoryStream()
+	streams.memory = newRingBuffer(options.MemoryBytes, options.MemoryLines)
 	return &Client{
 		streams:       streams,
 		isErrorStream: isErrorStream,
@@ -60,8 +85,8 @@ func (client *Client) Stream() io.Writer {
 
 // Clear the streams
 func (client *Client) Clear() error {
-	client.streams.memory.Truncate(0)
-	return client.streams.file.Truncate(0)
+	client.streams.memory.Reset()
+	return client.streams.file.Truncate()
 }
 
 // Get the in-memory stream
@@ -78,10 +103,26 @@ func (client *Client) Close() error {
 	return client.streams.file.Close()
 }
 
-func getFileFromPath(filePath string) (*os.File, error) {
-	return os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0777)
+func withDefaults(options *Options) *Options {
+	withDefaults := &Options{}
+	if options != nil {
+		*withDefaults = *options
+	}
+	if withDefaults.MaxSize <= 0 {
+		withDefaults.MaxSize = 10 * 1024 * 1024
+	}
+	if withDefaults.MaxBackups <= 0 {
+		withDefaults.MaxBackups = 5
+	}
+	if withDefaults.MemoryBytes <= 0 {
+		withDefaults.MemoryBytes = 64 * 1024
+	}
+	if withDefaults.MemoryLines <= 0 {
+		withDefaults.MemoryLines = 1000
+	}
+	return withDefaults
 }
 
-func memoryStream() *bytes.Buffer {
-	return &bytes.Buffer{}
+func getFileFromPath(filePath string) (*os.File, error) {
+	return os.OpenFile(filePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0777)
 }
@@ -0,0 +1,159 @@
+package logger_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logger", func() {
+	var dir, filePath string
+	var sut logger.Logger
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "logger-test")
+		filePath = filepath.Join(dir, "connector.log")
+	})
+
+	AfterEach(func() {
+		sut.Close()
+		os.RemoveAll(dir)
+	})
+
+	Describe("when the file grows past the MaxSize", func() {
+		BeforeEach(func() {
+			var err error
+			sut, err = logger.NewLogger(filePath, false, &logger.Options{MaxSize: 100, MaxBackups: 2})
+			Expect(err).To(BeNil())
+			stream := sut.Stream()
+			for i := 0; i < 10; i++ {
+				fmt.Fprintln(stream, strings.Repeat("x", 59))
+			}
+		})
+
+		It("should keep the current file under the MaxSize", func() {
+			info, err := os.Stat(filePath)
+			Expect(err).To(BeNil())
+			Expect(info.Size()).To(BeNumerically("<=", 100))
+		})
+
+		It("should retain the MaxBackups", func() {
+			Eventually(func() int {
+				backups, _ := filepath.Glob(filePath + ".*")
+				return len(backups)
+			}).Should(Equal(2))
+		})
+	})
+
+	Describe("when the rotation fails to rename the file", func() {
+		var restore func()
+
+		BeforeEach(func() {
+			var err error
+			sut, err = logger.NewLogger(filePath, false, &logger.Options{MaxSize: 100, MaxBackups: 2})
+			Expect(err).To(BeNil())
+			restore = logger.FailRenames()
+		})
+
+		AfterEach(func() {
+			restore()
+		})
+
+		It("should keep writing to the file", func() {
+			stream := sut.Stream()
+			for i := 0; i < 3; i++ {
+				_, err := fmt.Fprintln(stream, strings.Repeat("x", 59))
+				Expect(err).To(BeNil())
+			}
+			data, err := ioutil.ReadFile(filePath)
+			Expect(err).To(BeNil())
+			Expect(data).To(HaveLen(180))
+		})
+
+		It("should rotate once the rename works again", func() {
+			stream := sut.Stream()
+			fmt.Fprintln(stream, strings.Repeat("x", 59))
+			fmt.Fprintln(stream, strings.Repeat("x", 59))
+			restore()
+			fmt.Fprintln(stream, strings.Repeat("x", 59))
+			backups, _ := filepath.Glob(filePath + ".*")
+			Expect(backups).To(HaveLen(1))
+		})
+	})
+
+	Describe("when a sibling file shares the name", func() {
+		var siblingPath string
+
+		BeforeEach(func() {
+			siblingPath = filePath + ".err"
+			Expect(ioutil.WriteFile(siblingPath, []byte("not a backup"), 0644)).To(Succeed())
+			var err error
+			sut, err = logger.NewLogger(filePath, false, &logger.Options{MaxSize: 100, MaxBackups: 1})
+			Expect(err).To(BeNil())
+			stream := sut.Stream()
+			for i := 0; i < 10; i++ {
+				fmt.Fprintln(stream, strings.Repeat("x", 59))
+			}
+		})
+
+		It("should only remove the backups", func() {
+			Eventually(func() int {
+				backups, _ := filepath.Glob(filePath + ".2*")
+				return len(backups)
+			}).Should(Equal(1))
+			Consistently(func() error {
+				_, err := os.Stat(siblingPath)
+				return err
+			}, 200*time.Millisecond).Should(Succeed())
+		})
+	})
+
+	Describe("when Compress is set", func() {
+		BeforeEach(func() {
+			sut, _ = logger.NewLogger(filePath, false, &logger.Options{MaxSize: 10, Compress: true})
+			stream := sut.Stream()
+			fmt.Fprintln(stream, "first line")
+			fmt.Fprintln(stream, "second line")
+		})
+
+		It("should gzip the rotated file", func() {
+			Eventually(func() []string {
+				backups, _ := filepath.Glob(filePath + ".*.gz")
+				return backups
+			}, time.Second).Should(HaveLen(1))
+		})
+	})
+
+	Describe("when the output is larger than the memory capture", func() {
+		BeforeEach(func() {
+			sut, _ = logger.NewLogger(filePath, false, &logger.Options{MemoryLines: 3})
+			stream := sut.Stream()
+			for i := 0; i < 10; i++ {
+				fmt.Fprintf(stream, "line %v\n", i)
+			}
+		})
+
+		It("should keep the last lines", func() {
+			Expect(string(sut.Get())).To(Equal("line 7\nline 8\nline 9\n"))
+		})
+	})
+
+	Describe("when a line is larger than the MemoryBytes", func() {
+		BeforeEach(func() {
+			sut, _ = logger.NewLogger(filePath, false, &logger.Options{MemoryBytes: 8})
+			fmt.Fprint(sut.Stream(), "0123456789")
+		})
+
+		It("should keep the last bytes", func() {
+			Expect(string(sut.Get())).To(Equal("23456789"))
+		})
+	})
+})
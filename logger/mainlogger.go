@@ -1,10 +1,12 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/kardianos/osext"
@@ -12,12 +14,22 @@ import (
 
 var mainLogger MainLogger
 
+// Log formats supported by the main logger
+const (
+	TextFormat = "text"
+	JSONFormat = "json"
+)
+
+// Fields defines structured values attached to a log line
+type Fields map[string]interface{}
+
 // MainClient defines the mainlogger struct
 type MainClient struct {
 	file           *os.File
 	fileStream     io.Writer
 	stderr         io.Writer
 	currentVersion string
+	format         string
 }
 
 // MainLogger defines the interface for logging to stderr or stdout
@@ -26,6 +38,18 @@ type MainLogger interface {
 	Close() error
 	Info(key, msg string)
 	Error(key, msg string, err error)
+	InfoWithFields(key, msg string, fields Fields)
+	ErrorWithFields(key, msg string, err error, fields Fields)
+}
+
+type jsonLine struct {
+	Level     string `json:"level"`
+	Time      string `json:"time"`
+	Version   string `json:"version"`
+	Component string `json:"component"`
+	Message   string `json:"message"`
+	Error     string `json:"error,omitempty"`
+	Fields    Fields `json:"fields,omitempty"`
 }
 
 // GetMainLogger gets the global instance of the MainLogger
@@ -50,32 +74,108 @@ func InitMainLogger(currentVersion string) error {
 		fileStream:     fileStream,
 		stderr:         stderr,
 		currentVersion: currentVersion,
+		format:         TextFormat,
 	}
 	return nil
 }
 
+// SetMainLogFormat sets the format of the main log file, text or json
+func SetMainLogFormat(format string) error {
+	if format == "" {
+		format = TextFormat
+	}
+	if format != TextFormat && format != JSONFormat {
+		return fmt.Errorf("Unknown log format %v", format)
+	}
+	client, ok := mainLogger.(*MainClient)
+	if !ok {
+		return nil
+	}
+	client.format = format
+	return nil
+}
+
 // Info log a message
 func (client *MainClient) Info(key, msg string) {
+	client.InfoWithFields(key, msg, nil)
+}
+
+// Error log a message
+func (client *MainClient) Error(key, msg string, err error) {
+	client.ErrorWithFields(key, msg, err, nil)
+}
+
+// InfoWithFields log a message with structured fields
+func (client *MainClient) InfoWithFields(key, msg string, fields Fields) {
 	timestamp := time.Now()
-	logMessage := fmt.Sprintf("( %s )[info][v%s][%s] %s", timestamp, client.currentVersion, key, msg)
-	prettyMessage := fmt.Sprintf("%s[info]%s[%s][v%s][%s%s%s] %s", cyan, reset, timestamp.Format("15:04:05.000"), client.currentVersion, magenta, key, reset, msg)
-	fmt.Fprintln(client.fileStream, logMessage)
+	if client.format == JSONFormat {
+		client.writeJSON("info", timestamp, key, msg, nil, fields)
+	} else {
+		logMessage := fmt.Sprintf("( %s )[info][v%s][%s] %s%s", timestamp, client.currentVersion, key, msg, formatFields(fields))
+		fmt.Fprintln(client.fileStream, logMessage)
+	}
 	if IsTerminal() {
+		prettyMessage := fmt.Sprintf("%s[info]%s[%s][v%s][%s%s%s] %s%s", cyan, reset, timestamp.Format("15:04:05.000"), client.currentVersion, magenta, key, reset, msg, formatFields(fields))
 		fmt.Fprintln(client.stderr, prettyMessage)
 	}
 }
 
-// Error log a message
-func (client *MainClient) Error(key, msg string, err error) {
+// ErrorWithFields log a message with structured fields
+func (client *MainClient) ErrorWithFields(key, msg string, err error, fields Fields) {
 	timestamp := time.Now()
-	logMessage := fmt.Sprintf("( %s )[error][v%s][%s] %s %s", timestamp, client.currentVersion, key, msg, err.Error())
-	prettyMessage := fmt.Sprintf("%s[error]%s[%s][v%s][%s%s%s] %s %s", red, reset, timestamp.Format("15:04:05.000"), client.currentVersion, cyan, key, reset, msg, err.Error())
-	fmt.Fprintln(client.fileStream, logMessage)
+	errMessage := ""
+	if err != nil {
+		errMessage = " " + err.Error()
+	}
+	if client.format == JSONFormat {
+		client.writeJSON("error", timestamp, key, msg, err, fields)
+	} else {
+		logMessage := fmt.Sprintf("( %s )[error][v%s][%s] %s%s%s", timestamp, client.currentVersion, key, msg, errMessage, formatFields(fields))
+		fmt.Fprintln(client.fileStream, logMessage)
+	}
 	if IsTerminal() {
+		prettyMessage := fmt.Sprintf("%s[error]%s[%s][v%s][%s%s%s] %s%s%s", red, reset, timestamp.Format("15:04:05.000"), client.currentVersion, cyan, key, reset, msg, errMessage, formatFields(fields))
 		fmt.Fprintln(client.stderr, prettyMessage)
 	}
 }
 
+func (client *MainClient) writeJSON(level string, timestamp time.Time, key, msg string, err error, fields Fields) {
+	line := &jsonLine{
+		Level:     level,
+		Time:      timestamp.Format(time.RFC3339),
+		Version:   client.currentVersion,
+		Component: key,
+		Message:   msg,
+		Fields:    fields,
+	}
+	if err != nil {
+		line.Error = err.Error()
+	}
+	data, jsonErr := json.Marshal(line)
+	if jsonErr != nil {
+		line.Fields = nil
+		line.Error = fmt.Sprintf("%v (fields: %v)", line.Error, jsonErr.Error())
+		data, _ = json.Marshal(line)
+	}
+	fmt.Fprintln(client.fileStream, string(data))
+}
+
+func formatFields(fields Fields) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	formatted := ""
+	for _, key := range keys {
+		formatted += fmt.Sprintf(" %s=%v", key, fields[key])
+	}
+	return formatted
+}
+
 // Clear the stream
 func (client *MainClient) Clear() error {
 	return client.file.Truncate(0)
@@ -0,0 +1,76 @@
+package logger_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MainLogger", func() {
+	var output *bytes.Buffer
+
+	BeforeEach(func() {
+		output = &bytes.Buffer{}
+	})
+
+	Describe("with the json format", func() {
+		var sut logger.MainLogger
+
+		BeforeEach(func() {
+			sut = logger.NewTestMainLogger(output, logger.JSONFormat)
+		})
+
+		It("should write an info line", func() {
+			sut.InfoWithFields("program", "connector started", logger.Fields{"pid": 42})
+			line := map[string]interface{}{}
+			Expect(json.Unmarshal(output.Bytes(), &line)).To(Succeed())
+			Expect(line).To(HaveKeyWithValue("level", "info"))
+			Expect(line).To(HaveKeyWithValue("version", "1.2.3"))
+			Expect(line).To(HaveKeyWithValue("component", "program"))
+			Expect(line).To(HaveKeyWithValue("message", "connector started"))
+			Expect(line).To(HaveKeyWithValue("fields", map[string]interface{}{"pid": float64(42)}))
+			Expect(line).To(HaveKey("time"))
+			Expect(line).NotTo(HaveKey("error"))
+		})
+
+		It("should write an error line", func() {
+			sut.Error("program", "connector crashed", errors.New("oh no"))
+			line := map[string]interface{}{}
+			Expect(json.Unmarshal(output.Bytes(), &line)).To(Succeed())
+			Expect(line).To(HaveKeyWithValue("level", "error"))
+			Expect(line).To(HaveKeyWithValue("error", "oh no"))
+			Expect(line).NotTo(HaveKey("fields"))
+		})
+
+		It("should write an error line without an error", func() {
+			sut.Error("program", "connector failed", nil)
+			line := map[string]interface{}{}
+			Expect(json.Unmarshal(output.Bytes(), &line)).To(Succeed())
+			Expect(line).To(HaveKeyWithValue("message", "connector failed"))
+			Expect(line).NotTo(HaveKey("error"))
+		})
+	})
+
+	Describe("with the text format", func() {
+		var sut logger.MainLogger
+
+		BeforeEach(func() {
+			sut = logger.NewTestMainLogger(output, logger.TextFormat)
+		})
+
+		It("should write the error and fields", func() {
+			sut.ErrorWithFields("program", "connector crashed", errors.New("oh no"), logger.Fields{"code": 1})
+			Expect(output.String()).To(HaveSuffix("[error][v1.2.3][program] connector crashed oh no code=1\n"))
+		})
+
+		It("should write an error line without an error", func() {
+			sut.Error("program", "connector failed", nil)
+			Expect(output.String()).To(HaveSuffix("[error][v1.2.3][program] connector failed\n"))
+		})
+	})
+})
@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"bytes"
+	"sync"
+)
+
+// ringBuffer keeps the last maxBytes / maxLines written to it,
+// dropping whole lines from the front when possible
+type ringBuffer struct {
+	mutex    sync.Mutex
+	data     []byte
+	maxBytes int
+	maxLines int
+}
+
+func newRingBuffer(maxBytes, maxLines int) *ringBuffer {
+	return &ringBuffer{
+		data:     []byte{},
+		maxBytes: maxBytes,
+		maxLines: maxLines,
+	}
+}
+
+// Write appends to the buffer and drops the oldest data
+func (buf *ringBuffer) Write(p []byte) (int, error) {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+	buf.data = append(buf.data, p...)
+	buf.trim()
+	return len(p), nil
+}
+
+// Bytes returns a copy of the buffered data
+func (buf *ringBuffer) Bytes() []byte {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+	return append([]byte{}, buf.data...)
+}
+
+// Reset empties the buffer
+func (buf *ringBuffer) Reset() {
+	buf.mutex.Lock()
+	defer buf.mutex.Unlock()
+	buf.data = []byte{}
+}
+
+func (buf *ringBuffer) trim() {
+	cut := 0
+	if buf.maxBytes > 0 && len(buf.data) > buf.maxBytes {
+		cut = len(buf.data) - buf.maxBytes
+		if newline := bytes.IndexByte(buf.data[cut:], '\n'); newline >= 0 && newline < len(buf.data)-cut-1 {
+			cut += newline + 1
+		}
+	}
+	if buf.maxLines > 0 {
+		lines := bytes.Count(buf.data[cut:], []byte{'\n'})
+		for lines > buf.maxLines {
+			newline := bytes.IndexByte(buf.data[cut:], '\n')
+			cut += newline + 1
+			lines--
+		}
+	}
+	if cut > 0 {
+		buf.data = append([]byte{}, buf.data[cut:]...)
+	}
+}
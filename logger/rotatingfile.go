@@ -0,0 +1,215 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const backupTimeFormat = "20060102T150405.000"
+
+// backupSuffixRegexp matches the backupTimeFormat suffix of the backups
+var backupSuffixRegexp = regexp.MustCompile(`^\d{8}T\d{6}\.\d{3}(-\d+)?(\.gz)?$`)
+
+// renameFile moves the file to its backup
+var renameFile = os.Rename
+
+// rotatingFile is a log file that is rotated by size and age
+type rotatingFile struct {
+	mutex        sync.Mutex
+	cleanupMutex sync.Mutex
+	path         string
+	options      *Options
+	file         *os.File
+	size         int64
+	openedAt     time.Time
+}
+
+func openRotatingFile(path string, options *Options) (*rotatingFile, error) {
+	rotating := &rotatingFile{
+		path:    path,
+		options: options,
+	}
+	err := rotating.open()
+	if err != nil {
+		return nil, err
+	}
+	return rotating, nil
+}
+
+// Write writes to the file, rotating it first when needed. When
+// the rotation fails it keeps writing to the file at the path
+func (rotating *rotatingFile) Write(p []byte) (int, error) {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+	if rotating.file == nil {
+		err := rotating.open()
+		if err != nil {
+			return 0, err
+		}
+	} else if rotating.shouldRotate(len(p)) {
+		err := rotating.rotate()
+		if rotating.file == nil {
+			return 0, err
+		}
+	}
+	n, err := rotating.file.Write(p)
+	rotating.size += int64(n)
+	return n, err
+}
+
+// Truncate empties the current file
+func (rotating *rotatingFile) Truncate() error {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+	if rotating.file == nil {
+		return nil
+	}
+	rotating.size = 0
+	return rotating.file.Truncate(0)
+}
+
+// Close closes the current file
+func (rotating *rotatingFile) Close() error {
+	rotating.mutex.Lock()
+	defer rotating.mutex.Unlock()
+	if rotating.file == nil {
+		return nil
+	}
+	file := rotating.file
+	rotating.file = nil
+	return file.Close()
+}
+
+func (rotating *rotatingFile) shouldRotate(length int) bool {
+	if rotating.size == 0 {
+		return false
+	}
+	if rotating.options.MaxSize > 0 && rotating.size+int64(length) > rotating.options.MaxSize {
+		return true
+	}
+	if rotating.options.RotateEvery > 0 && time.Since(rotating.openedAt) > rotating.options.RotateEvery {
+		return true
+	}
+	return false
+}
+
+func (rotating *rotatingFile) open() error {
+	file, err := getFileFromPath(rotating.path)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	rotating.file = file
+	rotating.size = info.Size()
+	rotating.openedAt = time.Now()
+	return nil
+}
+
+// rotate moves the file to a backup and opens a new one, the
+// path is reopened whatever failed, file is only nil when
+// that failed too
+func (rotating *rotatingFile) rotate() error {
+	err := rotating.file.Close()
+	rotating.file = nil
+	backupPath := fmt.Sprintf("%s.%s", rotating.path, time.Now().Format(backupTimeFormat))
+	if err == nil {
+		for i := 1; fileExists(backupPath) || fileExists(backupPath+".gz"); i++ {
+			backupPath = fmt.Sprintf("%s.%s-%d", rotating.path, time.Now().Format(backupTimeFormat), i)
+		}
+		err = renameFile(rotating.path, backupPath)
+	}
+	openErr := rotating.open()
+	if openErr != nil {
+		return openErr
+	}
+	if err != nil {
+		return err
+	}
+	go rotating.cleanup(backupPath)
+	return nil
+}
+
+// cleanup compresses the new backup and removes the expired ones
+func (rotating *rotatingFile) cleanup(backupPath string) {
+	rotating.cleanupMutex.Lock()
+	defer rotating.cleanupMutex.Unlock()
+	if rotating.options.Compress {
+		compressFile(backupPath)
+	}
+	matches, err := filepath.Glob(fmt.Sprintf("%s.*", rotating.path))
+	if err != nil {
+		return
+	}
+	backups := []string{}
+	for _, match := range matches {
+		if isBackupOf(rotating.path, match) {
+			backups = append(backups, match)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	for i, backup := range backups {
+		if rotating.options.MaxBackups > 0 && i >= rotating.options.MaxBackups {
+			os.Remove(backup)
+			continue
+		}
+		if rotating.options.MaxAge > 0 {
+			info, err := os.Stat(backup)
+			if err == nil && time.Since(info.ModTime()) > rotating.options.MaxAge {
+				os.Remove(backup)
+			}
+		}
+	}
+}
+
+// isBackupOf returns true if the file is a rotated backup of the path,
+// named with the rotation time and optionally compressed
+func isBackupOf(path, file string) bool {
+	prefix := filepath.Base(path) + "."
+	name := filepath.Base(file)
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	return backupSuffixRegexp.MatchString(strings.TrimPrefix(name, prefix))
+}
+
+func compressFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(target)
+	_, err = io.Copy(writer, source)
+	if err == nil {
+		err = writer.Close()
+	}
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+	return os.Remove(path)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -2,13 +2,18 @@ package main
 
 import (
 	"fmt"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/codegangsta/cli"
 	"github.com/coreos/go-semver/semver"
+	"github.com/octoblu/go-meshblu-connector-ignition/control"
 	"github.com/octoblu/go-meshblu-connector-ignition/forever"
+	"github.com/octoblu/go-meshblu-connector-ignition/installer"
 	"github.com/octoblu/go-meshblu-connector-ignition/logger"
 	"github.com/octoblu/go-meshblu-connector-ignition/runner"
 )
@@ -16,14 +21,284 @@ import (
 var mainLogger logger.MainLogger
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == runner.ChildCommand {
+		execConnector(os.Args[2:])
+		return
+	}
 	app := cli.NewApp()
 	app.Name = "meshblu-connector-ignition"
 	app.Version = version()
 	app.Action = run
-	app.Flags = []cli.Flag{}
+	app.Flags = []cli.Flag{
+		cli.BoolFlag{
+			Name:   "disable-self-update",
+			Usage:  "do not update the ignition",
+			EnvVar: "MESHBLU_CONNECTOR_IGNITION_DISABLE_SELF_UPDATE",
+		},
+		cli.StringFlag{
+			Name:   "log-format",
+			Usage:  "format of the ignition log file, text or json",
+			EnvVar: "MESHBLU_CONNECTOR_IGNITION_LOG_FORMAT",
+		},
+	}
+	app.Commands = []cli.Command{
+		{
+			Name:      "ctl",
+			Usage:     "control the running ignition",
+			ArgsUsage: fmt.Sprintf("<%s>", strings.Join(control.Commands, "|")),
+			Action:    ctl,
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "lines, n",
+					Usage: "number of lines to show for tail-logs",
+					Value: 20,
+				},
+				cli.StringFlag{
+					Name:  "connector, c",
+					Usage: "service or connector name to control, defaults to all connectors",
+				},
+			},
+		},
+		{
+			Name:   "validate",
+			Usage:  "check the service.json and meshblu.json of the connector",
+			Action: validate,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "config",
+					Usage: "path of the service.json, defaults to the one next to the ignition",
+				},
+			},
+		},
+		{
+			Name:   "install",
+			Usage:  "register the ignition as a service of the OS",
+			Action: install,
+		},
+		{
+			Name:   "uninstall",
+			Usage:  "remove the ignition service",
+			Action: serviceAction("uninstall"),
+		},
+		{
+			Name:   "start",
+			Usage:  "start the ignition service",
+			Action: serviceAction("start"),
+		},
+		{
+			Name:   "stop",
+			Usage:  "stop the ignition service",
+			Action: serviceAction("stop"),
+		},
+		{
+			Name:   "restart",
+			Usage:  "restart the ignition service",
+			Action: serviceAction("restart"),
+		},
+		{
+			Name:   "status",
+			Usage:  "show the state of the ignition service",
+			Action: serviceStatus,
+		},
+		{
+			Name:  "secrets",
+			Usage: "manage the encrypted secret store of the connector",
+			Subcommands: []cli.Command{
+				{
+					Name:   "import",
+					Usage:  "move the meshblu.json of the connector into the secret store",
+					Action: importSecrets,
+					Flags: []cli.Flag{
+						cli.BoolFlag{
+							Name:  "keep",
+							Usage: "keep the plaintext meshblu.json",
+						},
+					},
+				},
+				{
+					Name:      "set",
+					Usage:     "set a secret handed to the connector",
+					ArgsUsage: "<key> <value>",
+					Action:    setSecret,
+				},
+			},
+		},
+	}
 	app.Run(os.Args)
 }
 
+// execConnector is the connector process started by the runner,
+// it only gets here when the connector could not be executed
+func execConnector(args []string) {
+	err := runner.RunChild(args)
+	fmt.Fprintf(os.Stderr, "meshblu-connector-ignition: %v\n", err)
+	os.Exit(127)
+}
+
+func ctl(context *cli.Context) error {
+	command := context.Args().First()
+	if !control.IsCommand(command) {
+		cli.ShowCommandHelp(context, "ctl")
+		return cli.NewExitError(fmt.Sprintf("unknown command: %v", command), 1)
+	}
+	response, err := control.Send(&control.Request{
+		Command:   command,
+		Lines:     context.Int("lines"),
+		Connector: context.String("connector"),
+	})
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	if response.Result != "" {
+		fmt.Println(response.Result)
+	}
+	if response.Error != "" {
+		return cli.NewExitError(response.Error, 1)
+	}
+	return nil
+}
+
+func validate(context *cli.Context) error {
+	var serviceConfig *runner.Config
+	var err error
+	if context.String("config") != "" {
+		serviceConfig, err = runner.ReadConfig(context.String("config"))
+	} else {
+		serviceConfig, err = runner.GetConfig()
+	}
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	err = serviceConfig.Validate()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	fmt.Println("config is valid")
+	return nil
+}
+
+func install(context *cli.Context) error {
+	serviceConfig, err := runner.GetConfig()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	err = serviceConfig.Validate()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	serviceInstaller, err := installer.New(serviceConfig)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	err = serviceInstaller.Install()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
+
+func serviceAction(action string) func(*cli.Context) error {
+	return func(context *cli.Context) error {
+		serviceInstaller, err := getInstaller()
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		switch action {
+		case "uninstall":
+			err = serviceInstaller.Uninstall()
+		case "start":
+			err = serviceInstaller.Start()
+		case "stop":
+			err = serviceInstaller.Stop()
+		case "restart":
+			err = serviceInstaller.Restart()
+		}
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		return nil
+	}
+}
+
+func serviceStatus(context *cli.Context) error {
+	serviceInstaller, err := getInstaller()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	state, err := serviceInstaller.Status()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	fmt.Println(state)
+	return nil
+}
+
+func getInstaller() (installer.Installer, error) {
+	serviceConfig, err := runner.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	return installer.New(serviceConfig)
+}
+
+func importSecrets(context *cli.Context) error {
+	serviceConfig, err := runner.GetConfig()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	meshbluConfigPath := filepath.Join(serviceConfig.Dir, runner.MeshbluConfigName)
+	data, err := ioutil.ReadFile(meshbluConfigPath)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	err = updateSecrets(serviceConfig, runner.MeshbluConfigName, string(data))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	if context.Bool("keep") {
+		return nil
+	}
+	err = os.Remove(meshbluConfigPath)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
+
+func setSecret(context *cli.Context) error {
+	if context.NArg() != 2 {
+		cli.ShowCommandHelp(context, "set")
+		return cli.NewExitError("a key and value are required", 1)
+	}
+	serviceConfig, err := runner.GetConfig()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	err = updateSecrets(serviceConfig, context.Args().Get(0), context.Args().Get(1))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
+
+// updateSecrets writes the value to the secret store, only when the
+// SecretsMode is set since the runner ignores the store otherwise
+func updateSecrets(serviceConfig *runner.Config, key, value string) error {
+	if serviceConfig.SecretsMode == "" {
+		return fmt.Errorf("SecretsMode is not set in the service config, the connector would not read the secret store")
+	}
+	store, err := serviceConfig.GetSecretStore()
+	if err != nil {
+		return err
+	}
+	values, err := store.Read()
+	if err != nil {
+		return err
+	}
+	values[key] = value
+	return store.Write(values)
+}
+
 func run(context *cli.Context) {
 	err := logger.InitMainLogger(version())
 	if err != nil {
@@ -32,12 +307,29 @@ func run(context *cli.Context) {
 		return
 	}
 	mainLogger = logger.GetMainLogger()
+	logFormat := context.String("log-format")
+	err = logger.SetMainLogFormat(logFormat)
+	fatalIfErr(err, "Error setting log format")
 	mainLogger.Info("main", fmt.Sprintf("starting %v...", version()))
 	defer mainLogger.Clear()
 	defer mainLogger.Close()
 
 	serviceConfig, err := runner.GetConfig()
 	fatalIfErr(err, "Error getting service config")
+	err = serviceConfig.Validate()
+	if validationErr, ok := err.(*runner.ValidationError); ok && !validationErr.Structural {
+		// the files the config refers to may still show up, forever retries until then
+		mainLogger.Error("main", "Service config has problems, starting anyway", err)
+	} else {
+		fatalIfErr(err, "Error validating service config")
+	}
+	if logFormat == "" {
+		err = logger.SetMainLogFormat(serviceConfig.LogFormat)
+		fatalIfErr(err, "Error setting log format")
+	}
+	if context.Bool("disable-self-update") {
+		serviceConfig.DisableSelfUpdate = true
+	}
 
 	foreverClient := forever.NewRunner(serviceConfig, version())
 	err = foreverClient.Start()
@@ -0,0 +1,89 @@
+package pidlock
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Lock is an advisory lock of a file recording the pid of its holder
+type Lock struct {
+	file *os.File
+	path string
+}
+
+// LockedError is returned when another process holds the lock
+type LockedError struct {
+	Path string
+	PID  int
+
+	// Stale is true when the recorded pid is no longer alive,
+	// but the lock is still held by another process
+	Stale bool
+}
+
+func (err *LockedError) Error() string {
+	if err.PID == 0 {
+		return fmt.Sprintf("%s is locked by another process", err.Path)
+	}
+	if err.Stale {
+		return fmt.Sprintf("%s is locked by a process left behind by pid %v, which is no longer running", err.Path, err.PID)
+	}
+	return fmt.Sprintf("%s is locked by pid %v", err.Path, err.PID)
+}
+
+// New takes the lock of the file, recording the pid in it.
+// A lock left behind by a process that is no longer alive is taken over
+func New(path string, pid int) (*Lock, error) {
+	file, err := lockFile(path)
+	if err != nil {
+		return nil, err
+	}
+	lock := &Lock{file: file, path: path}
+	err = lock.writePID(pid)
+	if err != nil {
+		lock.Unlock()
+		return nil, err
+	}
+	return lock, nil
+}
+
+// Unlock releases the lock, it can be called more than once
+func (lock *Lock) Unlock() error {
+	if lock.file == nil {
+		return nil
+	}
+	file := lock.file
+	lock.file = nil
+	return unlockFile(file, lock.path)
+}
+
+// ReadPID returns the pid recorded in the lock file, or 0 if there is none
+func ReadPID(path string) (int, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	value := strings.TrimSpace(string(data))
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func (lock *Lock) writePID(pid int) error {
+	err := lock.file.Truncate(0)
+	if err != nil {
+		return err
+	}
+	_, err = lock.file.WriteAt([]byte(strconv.Itoa(pid)), 0)
+	if err != nil {
+		return err
+	}
+	return lock.file.Sync()
+}
@@ -0,0 +1,37 @@
+package pidlock_test
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/pidlock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+// holdLockEnv makes the test binary hold the lock of the path until it is killed
+const holdLockEnv = "PIDLOCK_TEST_HOLD"
+
+func init() {
+	path := os.Getenv(holdLockEnv)
+	if path == "" {
+		return
+	}
+	_, err := pidlock.New(path, os.Getpid())
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+	fmt.Println("locked")
+	time.Sleep(time.Minute)
+	os.Exit(0)
+}
+
+func TestPidlock(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Pidlock Suite")
+}
@@ -0,0 +1,116 @@
+package pidlock_test
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/pidlock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lock", func() {
+	var dir, path string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "pidlock")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "update.lock")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("when the lock is free", func() {
+		var lock *pidlock.Lock
+
+		BeforeEach(func() {
+			var err error
+			lock, err = pidlock.New(path, os.Getpid())
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			lock.Unlock()
+		})
+
+		It("should record the pid", func() {
+			Expect(pidlock.ReadPID(path)).To(Equal(os.Getpid()))
+		})
+
+		It("should not be taken twice", func() {
+			_, err := pidlock.New(path, os.Getpid())
+			Expect(err).To(BeAssignableToTypeOf(&pidlock.LockedError{}))
+			Expect(err.(*pidlock.LockedError).PID).To(Equal(os.Getpid()))
+		})
+
+		It("should be taken again once unlocked", func() {
+			Expect(lock.Unlock()).To(Succeed())
+			Expect(lock.Unlock()).To(Succeed())
+			other, err := pidlock.New(path, os.Getpid())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(other.Unlock()).To(Succeed())
+		})
+	})
+
+	Describe("when another process holds the lock", func() {
+		var holder *exec.Cmd
+
+		BeforeEach(func() {
+			holder = exec.Command(os.Args[0], "-test.run=^$")
+			holder.Env = append(os.Environ(), "PIDLOCK_TEST_HOLD="+path)
+			stdout, err := holder.StdoutPipe()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(holder.Start()).To(Succeed())
+			line, err := bufio.NewReader(stdout).ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			Expect(line).To(Equal("locked\n"))
+		})
+
+		AfterEach(func() {
+			holder.Process.Kill()
+			holder.Wait()
+		})
+
+		It("should not take the lock", func() {
+			_, err := pidlock.New(path, os.Getpid())
+			Expect(err).To(BeAssignableToTypeOf(&pidlock.LockedError{}))
+			Expect(err.(*pidlock.LockedError).PID).To(Equal(holder.Process.Pid))
+			Expect(err.(*pidlock.LockedError).Stale).To(BeFalse())
+		})
+
+		Describe("when it crashed", func() {
+			It("should take over the lock", func() {
+				Expect(holder.Process.Kill()).To(Succeed())
+				holder.Wait()
+				Expect(pidlock.ReadPID(path)).To(Equal(holder.Process.Pid))
+				lock, err := pidlock.New(path, os.Getpid())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(pidlock.ReadPID(path)).To(Equal(os.Getpid()))
+				Expect(lock.Unlock()).To(Succeed())
+			})
+		})
+	})
+})
+
+var _ = Describe("IsAlive", func() {
+	It("should be true for the current process", func() {
+		Expect(pidlock.IsAlive(os.Getpid())).To(BeTrue())
+	})
+
+	It("should be false for an exited process", func() {
+		cmd := exec.Command(os.Args[0], "-test.run=^$")
+		Expect(cmd.Run()).To(Succeed())
+		Expect(pidlock.IsAlive(cmd.Process.Pid)).To(BeFalse())
+	})
+
+	It("should be false without a pid", func() {
+		Expect(pidlock.IsAlive(0)).To(BeFalse())
+	})
+})
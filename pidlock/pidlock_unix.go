@@ -0,0 +1,44 @@
+// +build !windows
+
+package pidlock
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile flocks the file, the kernel releases
+// the lock when the process holding it dies
+func lockFile(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	err = syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == syscall.EWOULDBLOCK {
+		file.Close()
+		pid, _ := ReadPID(path)
+		// a dead holder means a process it left behind inherited the lock
+		return nil, &LockedError{Path: path, PID: pid, Stale: pid != 0 && !IsAlive(pid)}
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+func unlockFile(file *os.File, path string) error {
+	file.Truncate(0)
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	return file.Close()
+}
+
+// IsAlive returns true if the process is running
+func IsAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,41 @@
+// +build !windows
+
+package pidlock_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/pidlock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lock on unix", func() {
+	var dir, path string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "pidlock")
+		Expect(err).NotTo(HaveOccurred())
+		path = filepath.Join(dir, "update.lock")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("when the recorded pid is dead but the lock is still held", func() {
+		It("should report a stale holder", func() {
+			lock, err := pidlock.New(path, 999999999)
+			Expect(err).NotTo(HaveOccurred())
+			defer lock.Unlock()
+			_, err = pidlock.New(path, os.Getpid())
+			Expect(err).To(BeAssignableToTypeOf(&pidlock.LockedError{}))
+			Expect(err.(*pidlock.LockedError).Stale).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("no longer running"))
+		})
+	})
+})
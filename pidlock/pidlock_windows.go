@@ -0,0 +1,72 @@
+package pidlock
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+)
+
+// staleEmptyLock is how old a lock file without a pid
+// has to be before it is considered abandoned
+const staleEmptyLock = 10 * time.Second
+
+// lockFile creates the lock file exclusively, removing it
+// first when the recorded process is no longer alive
+func lockFile(path string) (*os.File, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return file, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		pid, _ := ReadPID(path)
+		if !isStale(path, pid) {
+			return nil, &LockedError{Path: path, PID: pid}
+		}
+		err = os.Remove(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+	pid, _ := ReadPID(path)
+	return nil, &LockedError{Path: path, PID: pid}
+}
+
+func unlockFile(file *os.File, path string) error {
+	file.Close()
+	return os.Remove(path)
+}
+
+// isStale returns true if the lock file was left behind
+func isStale(path string, pid int) bool {
+	if pid != 0 {
+		return !IsAlive(pid)
+	}
+	info, err := os.Stat(path)
+	return err == nil && time.Since(info.ModTime()) > staleEmptyLock
+}
+
+// IsAlive returns true if the process is running
+func IsAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	handle, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if err == syscall.ERROR_ACCESS_DENIED {
+		return true
+	}
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+	var code uint32
+	err = syscall.GetExitCodeProcess(handle, &code)
+	return err != nil || code == stillActive
+}
@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"encoding/json"
+	"os/exec"
+
+	"github.com/kardianos/osext"
+)
+
+// ChildCommand is the hidden command the ignition runs itself with
+// to set up the connector process before it execs the connector,
+// so the umask, rlimits and cgroup are only changed in the connector process
+const ChildCommand = "exec-connector"
+
+// childSpec is what the child applies to itself before the exec,
+// CgroupFD is closed by the runner once it moved the child into
+// the cgroup of the connector
+type childSpec struct {
+	Umask    int           `json:"umask"`
+	Rlimits  []childRlimit `json:"rlimits,omitempty"`
+	CgroupFD int           `json:"cgroupFD,omitempty"`
+}
+
+// childRlimit sets both the soft and hard limit of the resource
+type childRlimit struct {
+	Resource int    `json:"resource"`
+	Value    uint64 `json:"value"`
+}
+
+// isEmpty returns true when the connector can be started directly
+func (spec *childSpec) isEmpty() bool {
+	return spec.Umask < 0 && len(spec.Rlimits) == 0 && spec.CgroupFD == 0
+}
+
+// wrapChild makes the command start the ignition with the ChildCommand,
+// the spec and the path and args of the connector
+func wrapChild(cmd *exec.Cmd, spec *childSpec) error {
+	executable, err := osext.Executable()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	cmd.Args = append([]string{executable, ChildCommand, string(data), cmd.Path}, cmd.Args...)
+	cmd.Path = executable
+	return nil
+}
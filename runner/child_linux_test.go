@@ -0,0 +1,140 @@
+package runner_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// the test binary is the ignition the connectors are started through
+func init() {
+	if len(os.Args) > 1 && os.Args[1] == runner.ChildCommand {
+		err := runner.RunChild(os.Args[2:])
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(127)
+	}
+}
+
+func getUmask() int {
+	umask := syscall.Umask(0)
+	syscall.Umask(umask)
+	return umask
+}
+
+var _ = Describe("Program.background", func() {
+	var dir string
+	var config *runner.Config
+	var output *bytes.Buffer
+
+	startInCgroup := func(cgroupPath, name string, args ...string) (*exec.Cmd, error) {
+		prg, err := runner.NewTestProgram(config, newFakeConnector("1.0.0"), &fakeStatus{}, &fakeUpdateConnector{})
+		Expect(err).NotTo(HaveOccurred())
+		cmd := exec.Command(name, args...)
+		cmd.Stdout = output
+		cmd.Stderr = output
+		group, err := prg.Background(cmd, cgroupPath)
+		if err != nil {
+			return cmd, err
+		}
+		return cmd, group.Wait()
+	}
+
+	start := func(name string, args ...string) (*exec.Cmd, error) {
+		return startInCgroup("", name, args...)
+	}
+
+	BeforeEach(func() {
+		config = newTestConfig("child")
+		dir = config.Dir
+		output = &bytes.Buffer{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("with a umask", func() {
+		BeforeEach(func() {
+			config.Umask = "0027"
+		})
+
+		It("should only set it in the connector", func() {
+			umask := getUmask()
+			_, err := start("/bin/sh", "-c", "umask; exit 3")
+			Expect(err).To(MatchError("exit status 3"))
+			Expect(output.String()).To(Equal("0027\n"))
+			Expect(getUmask()).To(Equal(umask))
+		})
+
+		It("should pass the args of the connector", func() {
+			_, err := start("/bin/echo", "hello", "world")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output.String()).To(Equal("hello world\n"))
+		})
+
+		Describe("when the connector cannot be executed", func() {
+			It("should exit with 127", func() {
+				_, err := start(filepath.Join(dir, "missing"))
+				Expect(err).To(MatchError("exit status 127"))
+				Expect(output.String()).To(ContainSubstring("no such file or directory"))
+			})
+		})
+	})
+
+	Describe("with rlimits", func() {
+		It("should set them in the connector", func() {
+			config.Limits.OpenFiles = 256
+			_, err := start("/bin/sh", "-c", "ulimit -n")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output.String()).To(Equal("256\n"))
+
+			var limit syscall.Rlimit
+			Expect(syscall.Getrlimit(syscall.RLIMIT_NOFILE, &limit)).To(Succeed())
+			Expect(limit.Cur).NotTo(BeEquivalentTo(256))
+		})
+
+		Describe("when one cannot be set", func() {
+			It("should not exec the connector", func() {
+				config.Limits.OpenFiles = 1 << 40
+				_, err := start("/bin/echo", "started")
+				Expect(err).To(MatchError("exit status 127"))
+				Expect(output.String()).To(ContainSubstring("error setting rlimit"))
+			})
+		})
+	})
+
+	// a plain directory stands in for the cgroup, the
+	// pid of the child is written to its cgroup.procs
+	Describe("with a cgroup", func() {
+		It("should move the connector into it before the exec", func() {
+			script := fmt.Sprintf(`test "$(cat %s)" = "$$" && echo joined`, filepath.Join(dir, "cgroup.procs"))
+			_, err := startInCgroup(dir, "/bin/sh", "-c", script)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(output.String()).To(Equal("joined\n"))
+		})
+
+		Describe("when the connector cannot be moved into it", func() {
+			It("should not exec the connector", func() {
+				_, err := startInCgroup(filepath.Join(dir, "missing"), "/bin/echo", "started")
+				Expect(err).To(MatchError("exit status 127"))
+				Expect(output.String()).To(ContainSubstring("did not move the connector into its cgroup"))
+			})
+		})
+	})
+
+	Describe("without a umask", func() {
+		It("should start the connector directly", func() {
+			cmd, err := start("/bin/sh", "-c", "exit 0")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cmd.Path).To(Equal("/bin/sh"))
+		})
+	})
+})
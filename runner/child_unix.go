@@ -0,0 +1,53 @@
+// +build !windows
+
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// RunChild applies the spec and replaces the process with
+// the connector, it only returns when that failed
+func RunChild(args []string) error {
+	if len(args) < 3 {
+		return fmt.Errorf("%s needs a spec, a path and the args", ChildCommand)
+	}
+	spec := &childSpec{}
+	err := json.Unmarshal([]byte(args[0]), spec)
+	if err != nil {
+		return err
+	}
+	if spec.CgroupFD > 0 {
+		err = waitForCgroup(spec.CgroupFD)
+		if err != nil {
+			return err
+		}
+	}
+	if spec.Umask >= 0 {
+		syscall.Umask(spec.Umask)
+	}
+	for _, rlimit := range spec.Rlimits {
+		limit := &syscall.Rlimit{Cur: rlimit.Value, Max: rlimit.Value}
+		err = syscall.Setrlimit(rlimit.Resource, limit)
+		if err != nil {
+			return fmt.Errorf("error setting rlimit %v to %v: %v", rlimit.Resource, rlimit.Value, err)
+		}
+	}
+	return syscall.Exec(args[1], args[2:], os.Environ())
+}
+
+// waitForCgroup blocks until the runner moved the process into
+// the cgroup of the connector, it writes a byte once it did
+func waitForCgroup(fd int) error {
+	file := os.NewFile(uintptr(fd), "cgroup")
+	defer file.Close()
+	joined := make([]byte, 1)
+	n, _ := file.Read(joined)
+	if n != 1 {
+		return fmt.Errorf("the runner did not move the connector into its cgroup")
+	}
+	return nil
+}
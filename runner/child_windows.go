@@ -0,0 +1,8 @@
+package runner
+
+import "fmt"
+
+// RunChild is not supported, the connector is started directly
+func RunChild(args []string) error {
+	return fmt.Errorf("%s is not supported on windows", ChildCommand)
+}
@@ -2,10 +2,25 @@ package runner
 
 import (
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"time"
 
+	"github.com/jpillora/backoff"
 	"github.com/kardianos/osext"
+	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+	"github.com/octoblu/go-meshblu-connector-ignition/secrets"
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+)
+
+// Release channels for ignition self updates
+const (
+	StableChannel = "stable"
+	BetaChannel   = "beta"
+	PinnedChannel = "pinned"
 )
 
 // Config is the runner connector config structure.
@@ -19,14 +34,381 @@ type Config struct {
 	BinPath        string
 	Dir            string
 	Stderr, Stdout string
+
+	// Command is the executable used to run the connector. A bare
+	// name is looked up in the BinPath, the connector Dir and then
+	// the PATH, a relative path is resolved against the Dir.
+	// Defaults to node from the BinPath running the
+	// meshblu-connector-runner
+	Command string
+
+	// Args are passed to the Command before the Entrypoint
+	Args []string
+
+	// Entrypoint is passed to the Command as the last argument.
+	// Only defaulted when the Command is also empty
+	Entrypoint string
+
+	// PublicKey is the base64 ed25519 key used to verify the
+	// signature of the connector checksum manifest
+	PublicKey string
+
+	// SkipChecksum disables checksum verification of connector
+	// downloads, it cannot be used with a PublicKey
+	SkipChecksum bool
+
+	// RollbackWindow is how long after a connector update the
+	// crashes count towards a rollback, defaults to 5m
+	RollbackWindow Duration
+
+	// RollbackCrashes is the number of crashes within the
+	// RollbackWindow that roll back the update, defaults to 3
+	RollbackCrashes int
+
+	// DisableRollback keeps a crashing connector update in place
+	DisableRollback bool
+
+	// ConnectorDownloadURL is the base url, directory or {placeholder}
+	// template of the connector release files, defaults to github
+	ConnectorDownloadURL string
+
+	// IgnitionDownloadURL is the base url, directory or {placeholder}
+	// template of the ignition binaries, defaults to github
+	IgnitionDownloadURL string
+
+	// IgnitionVersionURL is the url or file resolving the latest
+	// ignition version of the {channel}, defaults to the connector service
+	IgnitionVersionURL string
+
+	// UpdateChannel is the ignition release channel,
+	// one of stable, beta or pinned. Defaults to stable
+	UpdateChannel string
+
+	// PinnedVersion is the ignition version of the pinned channel
+	PinnedVersion string
+
+	// IgnitionMinVersion and IgnitionMaxVersion limit the
+	// versions the stable and beta channels update to
+	IgnitionMinVersion, IgnitionMaxVersion string
+
+	// DisableSelfUpdate turns off ignition self updates
+	DisableSelfUpdate bool
+
+	// LogFormat is the format of the ignition log file, text or json
+	LogFormat string
+
+	// LogMaxSize is the size in bytes before the Stdout and
+	// Stderr files are rotated, defaults to 10MB
+	LogMaxSize int64
+
+	// LogRotateEvery rotates the Stdout and Stderr files by age
+	LogRotateEvery Duration
+
+	// LogMaxBackups is the number of rotated files kept, defaults to 5
+	LogMaxBackups int
+
+	// LogMaxAge removes rotated files older than it
+	LogMaxAge Duration
+
+	// LogCompress gzips the rotated files
+	LogCompress bool
+
+	// LogMemoryBytes and LogMemoryLines bound the connector
+	// output kept in memory, defaults to 64KB and 1000 lines
+	LogMemoryBytes, LogMemoryLines int
+
+	// ErrorsDebounce is how long to collect connector error
+	// lines before sending them to the status device, defaults to 2s
+	ErrorsDebounce Duration
+
+	// ErrorsMinInterval is the minimum time between error
+	// updates of the status device, defaults to 10s
+	ErrorsMinInterval Duration
+
+	// ErrorsMaxEntries is the number of unique error lines
+	// on the status device, defaults to 20
+	ErrorsMaxEntries int
+
+	// Subscribe listens to the meshblu device stream for changes,
+	// polling every minute only while the stream is unavailable
+	Subscribe bool
+
+	// RestartPolicy is when the connector is restarted after it exits,
+	// one of always, on-failure or never. Defaults to always
+	RestartPolicy string
+
+	// BackoffMin and BackoffMax limit the delay before restarting
+	// a failing connector, default to 1s and 1m
+	BackoffMin, BackoffMax Duration
+
+	// BackoffFactor multiplies the delay after each restart, defaults to 2
+	BackoffFactor float64
+
+	// BackoffJitter randomizes the delay before restarting
+	BackoffJitter bool
+
+	// StabilityWindow is how long the connector has to run
+	// before the backoff is reset, defaults to 30s
+	StabilityWindow Duration
+
+	// MaxRestarts is the number of restarts within the RestartWindow
+	// that park the connector in a failed state, 0 disables it
+	MaxRestarts int
+
+	// RestartWindow is the period counted by MaxRestarts, defaults to 10m
+	RestartWindow Duration
+
+	// HealthChecks are the liveness checks of the running connector
+	HealthChecks []HealthCheck
+
+	// Limits are the resource limits of the connector process
+	Limits Limits
+
+	// User and Group run the connector as another account on Linux,
+	// the Group defaults to the primary group of the User
+	User, Group string
+
+	// Groups are the supplementary groups of the connector on Linux
+	Groups []string
+
+	// Umask is the octal umask of the connector on Linux, like 0027
+	Umask string
+
+	// WorkingDir is the working directory of the connector,
+	// relative to the connector Dir. Defaults to the Dir
+	WorkingDir string
+
+	// Env are set on the connector env after the EnvFiles,
+	// expanding $VAR and ${VAR} from the env before them
+	Env map[string]string
+
+	// EnvFiles are .env files of KEY=VALUE lines relative
+	// to the connector Dir, also expanding $VAR and ${VAR}
+	EnvFiles []string
+
+	// InheritEnv limits the variables inherited from the ignition
+	// env to the matching names, with * wildcards. Defaults to all
+	InheritEnv []string
+
+	// DenyEnv are not inherited from the ignition env,
+	// PATH and DEBUG are never inherited
+	DenyEnv []string
+
+	// SecretsMode enables the encrypted secret store, handed to the
+	// connector through an inherited fd or a one-shot socket.
+	// The meshblu.json is then read from the store
+	SecretsMode string
+
+	// SecretsPath is the encrypted secret store,
+	// defaults to secrets.enc in the connector Dir
+	SecretsPath string
+
+	// SecretsKeyFile is the machine specific file the store key
+	// is derived from, defaults to the machine-id
+	SecretsKeyFile string
+
+	// Connectors are the service.json files, or the directories
+	// containing one, of the connectors run by this ignition.
+	// Relative paths are resolved against the ignition directory
+	Connectors []string
+
+	// ConnectorsDir is a directory of connector service.json files,
+	// or of directories containing one, run by this ignition
+	ConnectorsDir string
+
+	// Systemd is the systemd unit written by the install command
+	Systemd Systemd
+}
+
+// Restart policies of the connector
+const (
+	RestartAlways    = "always"
+	RestartOnFailure = "on-failure"
+	RestartNever     = "never"
+)
+
+// GetLogOptions returns the rotation options for the Stdout and Stderr files
+func (config *Config) GetLogOptions() *logger.Options {
+	return &logger.Options{
+		MaxSize:     config.LogMaxSize,
+		RotateEvery: time.Duration(config.LogRotateEvery),
+		MaxBackups:  config.LogMaxBackups,
+		MaxAge:      time.Duration(config.LogMaxAge),
+		Compress:    config.LogCompress,
+		MemoryBytes: config.LogMemoryBytes,
+		MemoryLines: config.LogMemoryLines,
+	}
+}
+
+// GetReporterOptions returns the options for sending errors to the status device
+func (config *Config) GetReporterOptions() status.ReporterOptions {
+	return status.ReporterOptions{
+		Debounce:    time.Duration(config.ErrorsDebounce),
+		MinInterval: time.Duration(config.ErrorsMinInterval),
+		MaxEntries:  config.ErrorsMaxEntries,
+	}
+}
+
+// GetRestartPolicy returns the restart policy, defaults to always
+func (config *Config) GetRestartPolicy() (string, error) {
+	switch config.RestartPolicy {
+	case "":
+		return RestartAlways, nil
+	case RestartAlways, RestartOnFailure, RestartNever:
+		return config.RestartPolicy, nil
+	}
+	return "", fmt.Errorf("invalid restart policy %s, must be one of %s, %s or %s", config.RestartPolicy, RestartAlways, RestartOnFailure, RestartNever)
+}
+
+// GetBackoff returns the backoff used to restart the connector
+func (config *Config) GetBackoff() *backoff.Backoff {
+	factor := config.BackoffFactor
+	if factor < 1 {
+		factor = 2
+	}
+	return &backoff.Backoff{
+		Min:    config.BackoffMin.OrDefault(time.Second),
+		Max:    config.BackoffMax.OrDefault(time.Minute),
+		Factor: factor,
+		Jitter: config.BackoffJitter,
+	}
+}
+
+// GetRollbackCrashes returns the number of crashes that roll back an update
+func (config *Config) GetRollbackCrashes() int {
+	if config.RollbackCrashes <= 0 {
+		return 3
+	}
+	return config.RollbackCrashes
+}
+
+// GetSecretStore returns the encrypted secret store of the connector
+func (config *Config) GetSecretStore() (*secrets.Store, error) {
+	if config.SecretsMode != "" && config.SecretsMode != secrets.ModeFD && config.SecretsMode != secrets.ModeSocket {
+		return nil, fmt.Errorf("invalid secrets mode %s, must be %s or %s", config.SecretsMode, secrets.ModeFD, secrets.ModeSocket)
+	}
+	key, err := secrets.DeriveKey(config.SecretsKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	path := config.SecretsPath
+	if path == "" {
+		path = filepath.Join(config.Dir, "secrets.enc")
+	}
+	return secrets.New(path, key)
+}
+
+// GetUmask returns the umask of the connector, or -1 when it is not set
+func (config *Config) GetUmask() (int, error) {
+	if config.Umask == "" {
+		return -1, nil
+	}
+	umask, err := strconv.ParseUint(config.Umask, 8, 32)
+	if err != nil || umask > 0777 {
+		return -1, fmt.Errorf("invalid umask %s, must be octal like 0027", config.Umask)
+	}
+	return int(umask), nil
+}
+
+// GetWorkingDir returns the working directory of the connector
+func (config *Config) GetWorkingDir() string {
+	if config.WorkingDir == "" {
+		return config.Dir
+	}
+	if filepath.IsAbs(config.WorkingDir) {
+		return config.WorkingDir
+	}
+	return filepath.Join(config.Dir, config.WorkingDir)
+}
+
+// GetCommand returns the executable name for the connector
+func (config *Config) GetCommand() string {
+	if config.Command == "" {
+		return "node"
+	}
+	return config.Command
+}
+
+// GetCommandArgs returns the arguments for the connector command
+func (config *Config) GetCommandArgs() []string {
+	args := append([]string{}, config.Args...)
+	entrypoint := config.Entrypoint
+	if entrypoint == "" && config.Command == "" {
+		entrypoint = fmt.Sprintf(".%s%s", string(filepath.Separator), filepath.Join("node_modules", "meshblu-connector-runner", "command.js"))
+	}
+	if entrypoint != "" {
+		args = append(args, entrypoint)
+	}
+	return args
+}
+
+// IsMulti returns true if the config lists the connectors to run
+func (config *Config) IsMulti() bool {
+	return len(config.Connectors) > 0 || config.ConnectorsDir != ""
+}
+
+// GetConnectorConfigs returns the configs of the connectors to run,
+// only the config itself unless it lists the Connectors or ConnectorsDir
+func (config *Config) GetConnectorConfigs() ([]*Config, error) {
+	if !config.IsMulti() {
+		return []*Config{config}, nil
+	}
+	baseDir, err := getIgnitionDir()
+	if err != nil {
+		return nil, err
+	}
+	paths := []string{}
+	for _, path := range config.Connectors {
+		paths = append(paths, resolvePath(baseDir, path))
+	}
+	if config.ConnectorsDir != "" {
+		connectorsDir := resolvePath(baseDir, config.ConnectorsDir)
+		entries, err := ioutil.ReadDir(connectorsDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) == ".json" {
+				paths = append(paths, filepath.Join(connectorsDir, entry.Name()))
+			}
+		}
+	}
+	configs := []*Config{}
+	serviceNames := map[string]bool{}
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			path = filepath.Join(path, "service.json")
+		}
+		connectorConfig, err := ReadConfig(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		if connectorConfig.IsMulti() {
+			return nil, fmt.Errorf("%s: a connector config cannot list other connectors", path)
+		}
+		if serviceNames[connectorConfig.ServiceName] {
+			return nil, fmt.Errorf("%s: duplicate ServiceName %s", path, connectorConfig.ServiceName)
+		}
+		serviceNames[connectorConfig.ServiceName] = true
+		configs = append(configs, connectorConfig)
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("no connector configs found")
+	}
+	return configs, nil
 }
 
 // GetConfig get the service config
 func GetConfig() (*Config, error) {
-	path, err := getConfigPath()
+	path, err := GetConfigPath()
 	if err != nil {
 		return nil, err
 	}
+	return ReadConfig(path)
+}
+
+// ReadConfig reads a service config file
+func ReadConfig(path string) (*Config, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -43,13 +425,28 @@ func GetConfig() (*Config, error) {
 	return conf, nil
 }
 
-func getConfigPath() (string, error) {
+// GetConfigPath returns the path of the service.json next to the ignition
+func GetConfigPath() (string, error) {
+	dir, err := getIgnitionDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "service.json"), nil
+}
+
+func getIgnitionDir() (string, error) {
 	fullexecpath, err := osext.Executable()
 	if err != nil {
 		return "", err
 	}
 
 	dir, _ := filepath.Split(fullexecpath)
+	return dir, nil
+}
 
-	return filepath.Join(dir, "service.json"), nil
+func resolvePath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(baseDir, path)
 }
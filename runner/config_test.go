@@ -0,0 +1,254 @@
+package runner_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	var sut *runner.Config
+
+	Describe("with no command", func() {
+		BeforeEach(func() {
+			sut = &runner.Config{}
+		})
+
+		It("should use node", func() {
+			Expect(sut.GetCommand()).To(Equal("node"))
+		})
+
+		It("should use the meshblu-connector-runner", func() {
+			commandPath := "." + string(filepath.Separator) + filepath.Join("node_modules", "meshblu-connector-runner", "command.js")
+			Expect(sut.GetCommandArgs()).To(Equal([]string{commandPath}))
+		})
+	})
+
+	Describe("with no command and args", func() {
+		BeforeEach(func() {
+			sut = &runner.Config{Args: []string{"--max-old-space-size=128"}}
+		})
+
+		It("should pass the args before the entrypoint", func() {
+			Expect(sut.GetCommandArgs()).To(HaveLen(2))
+			Expect(sut.GetCommandArgs()[0]).To(Equal("--max-old-space-size=128"))
+		})
+	})
+
+	Describe("with a command and entrypoint", func() {
+		BeforeEach(func() {
+			sut = &runner.Config{
+				Command:    "python3",
+				Args:       []string{"-u"},
+				Entrypoint: "main.py",
+			}
+		})
+
+		It("should use the command", func() {
+			Expect(sut.GetCommand()).To(Equal("python3"))
+		})
+
+		It("should pass the args and entrypoint", func() {
+			Expect(sut.GetCommandArgs()).To(Equal([]string{"-u", "main.py"}))
+		})
+	})
+
+	Describe("with a command and no entrypoint", func() {
+		BeforeEach(func() {
+			sut = &runner.Config{Command: "./connector"}
+		})
+
+		It("should not default the entrypoint", func() {
+			Expect(sut.GetCommandArgs()).To(BeEmpty())
+		})
+	})
+
+	Describe("with no restart policy", func() {
+		BeforeEach(func() {
+			sut = &runner.Config{}
+		})
+
+		It("should always restart", func() {
+			policy, err := sut.GetRestartPolicy()
+			Expect(err).To(BeNil())
+			Expect(policy).To(Equal(runner.RestartAlways))
+		})
+
+		It("should default the backoff", func() {
+			boff := sut.GetBackoff()
+			Expect(boff.Min).To(Equal(time.Second))
+			Expect(boff.Max).To(Equal(time.Minute))
+			Expect(boff.Factor).To(Equal(2.0))
+			Expect(boff.Jitter).To(BeFalse())
+		})
+	})
+
+	Describe("with a restart policy", func() {
+		BeforeEach(func() {
+			sut = &runner.Config{
+				RestartPolicy: runner.RestartOnFailure,
+				BackoffMin:    runner.Duration(5 * time.Second),
+				BackoffMax:    runner.Duration(10 * time.Minute),
+				BackoffFactor: 1.5,
+				BackoffJitter: true,
+			}
+		})
+
+		It("should use the restart policy", func() {
+			policy, err := sut.GetRestartPolicy()
+			Expect(err).To(BeNil())
+			Expect(policy).To(Equal(runner.RestartOnFailure))
+		})
+
+		It("should use the backoff", func() {
+			boff := sut.GetBackoff()
+			Expect(boff.Min).To(Equal(5 * time.Second))
+			Expect(boff.Max).To(Equal(10 * time.Minute))
+			Expect(boff.Factor).To(Equal(1.5))
+			Expect(boff.Jitter).To(BeTrue())
+		})
+	})
+
+	Describe("with an invalid restart policy", func() {
+		BeforeEach(func() {
+			sut = &runner.Config{RestartPolicy: "sometimes"}
+		})
+
+		It("should return an error", func() {
+			_, err := sut.GetRestartPolicy()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("with health checks", func() {
+		var check *runner.HealthCheck
+
+		It("should default the failure threshold", func() {
+			check = &runner.HealthCheck{Type: "tcp", Address: "localhost:80"}
+			Expect(check.GetFailureThreshold()).To(Equal(3))
+		})
+
+		It("should create the checker", func() {
+			check = &runner.HealthCheck{Type: "heartbeat", Path: "heartbeat"}
+			checker, err := check.GetChecker("/opt/connector")
+			Expect(err).To(BeNil())
+			Expect(checker).NotTo(BeNil())
+		})
+
+		It("should return an error for an invalid type", func() {
+			check = &runner.HealthCheck{Type: "ping"}
+			_, err := check.GetChecker("/opt/connector")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("with limits", func() {
+		It("should convert the cpu percent to the cgroup cpu.max", func() {
+			limits := &runner.Limits{CPUPercent: 50}
+			Expect(limits.GetCPUMax()).To(Equal("50000 100000"))
+		})
+
+		It("should default the cgroup path to the service name in the delegated cgroup", func() {
+			limits := &runner.Limits{}
+			Expect(limits.GetCgroupPath("/sys/fs/cgroup/system.slice/connectors.service", "MeshbluConnector-uuid")).To(Equal("/sys/fs/cgroup/system.slice/connectors.service/MeshbluConnector-uuid"))
+		})
+
+		It("should use the CgroupPath", func() {
+			limits := &runner.Limits{CgroupPath: "/sys/fs/cgroup/connectors/uuid"}
+			Expect(limits.GetCgroupPath("/sys/fs/cgroup/system.slice/connectors.service", "MeshbluConnector-uuid")).To(Equal("/sys/fs/cgroup/connectors/uuid"))
+		})
+
+		It("should only watch soft limits", func() {
+			Expect((&runner.Limits{MemoryBytes: 1024}).HasSoftLimits()).To(BeFalse())
+			Expect((&runner.Limits{SoftMemoryBytes: 1024}).HasSoftLimits()).To(BeTrue())
+		})
+	})
+
+	Describe("with an account", func() {
+		BeforeEach(func() {
+			sut = &runner.Config{
+				Dir:        "/opt/connector",
+				Umask:      "0027",
+				WorkingDir: "data",
+			}
+		})
+
+		It("should parse the umask", func() {
+			umask, err := sut.GetUmask()
+			Expect(err).To(BeNil())
+			Expect(umask).To(Equal(0027))
+		})
+
+		It("should return an error for an invalid umask", func() {
+			sut.Umask = "0999"
+			_, err := sut.GetUmask()
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should resolve the working dir against the connector dir", func() {
+			Expect(sut.GetWorkingDir()).To(Equal(filepath.Join("/opt/connector", "data")))
+		})
+
+		It("should default the working dir to the connector dir", func() {
+			sut.WorkingDir = ""
+			Expect(sut.GetWorkingDir()).To(Equal("/opt/connector"))
+		})
+	})
+
+	Describe("with connectors", func() {
+		var dir string
+
+		writeConfig := func(path, serviceName string) {
+			os.MkdirAll(filepath.Dir(path), 0755)
+			ioutil.WriteFile(path, []byte(fmt.Sprintf(`{"ServiceName":%q}`, serviceName)), 0644)
+		}
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "config-test")
+			writeConfig(filepath.Join(dir, "connectors", "hue.json"), "MeshbluConnector-hue")
+			writeConfig(filepath.Join(dir, "connectors", "say-hello", "service.json"), "MeshbluConnector-say-hello")
+			ioutil.WriteFile(filepath.Join(dir, "connectors", "README.md"), []byte("not a config"), 0644)
+			writeConfig(filepath.Join(dir, "other", "service.json"), "MeshbluConnector-other")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should only return itself without connectors", func() {
+			sut = &runner.Config{ServiceName: "MeshbluConnector-single"}
+			configs, err := sut.GetConnectorConfigs()
+			Expect(err).To(BeNil())
+			Expect(configs).To(Equal([]*runner.Config{sut}))
+		})
+
+		It("should read the connectors and the connectors dir", func() {
+			sut = &runner.Config{
+				Connectors:    []string{filepath.Join(dir, "other")},
+				ConnectorsDir: filepath.Join(dir, "connectors"),
+			}
+			configs, err := sut.GetConnectorConfigs()
+			Expect(err).To(BeNil())
+			serviceNames := []string{}
+			for _, config := range configs {
+				serviceNames = append(serviceNames, config.ServiceName)
+			}
+			Expect(serviceNames).To(Equal([]string{"MeshbluConnector-other", "MeshbluConnector-hue", "MeshbluConnector-say-hello"}))
+		})
+
+		It("should return an error for duplicate service names", func() {
+			sut = &runner.Config{
+				Connectors: []string{filepath.Join(dir, "other"), filepath.Join(dir, "other", "service.json")},
+			}
+			_, err := sut.GetConnectorConfigs()
+			Expect(err).To(MatchError(ContainSubstring("duplicate ServiceName")))
+		})
+	})
+})
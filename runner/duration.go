@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Duration is a time.Duration that can be decoded from
+// a duration string like "30s" or a number of seconds
+type Duration time.Duration
+
+// UnmarshalJSON decodes the duration from a string or number
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+	switch value := value.(type) {
+	case float64:
+		*duration = Duration(value * float64(time.Second))
+		return nil
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		*duration = Duration(parsed)
+		return nil
+	}
+	return fmt.Errorf("invalid duration %s", string(data))
+}
+
+// MarshalJSON encodes the duration as a duration string
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(duration).String())
+}
+
+// OrDefault returns the duration, or the default when it is not set
+func (duration Duration) OrDefault(defaultDuration time.Duration) time.Duration {
+	if duration <= 0 {
+		return defaultDuration
+	}
+	return time.Duration(duration)
+}
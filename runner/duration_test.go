@@ -0,0 +1,51 @@
+package runner_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Duration", func() {
+	Describe("when decoded from a string", func() {
+		It("should parse the duration", func() {
+			var duration runner.Duration
+			err := json.Unmarshal([]byte(`"1m30s"`), &duration)
+			Expect(err).To(BeNil())
+			Expect(time.Duration(duration)).To(Equal(90 * time.Second))
+		})
+	})
+
+	Describe("when decoded from a number", func() {
+		It("should use seconds", func() {
+			var duration runner.Duration
+			err := json.Unmarshal([]byte(`30`), &duration)
+			Expect(err).To(BeNil())
+			Expect(time.Duration(duration)).To(Equal(30 * time.Second))
+		})
+	})
+
+	Describe("when decoded from an invalid string", func() {
+		It("should return an error", func() {
+			var duration runner.Duration
+			err := json.Unmarshal([]byte(`"soon"`), &duration)
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("->OrDefault", func() {
+		It("should return the default when not set", func() {
+			var duration runner.Duration
+			Expect(duration.OrDefault(time.Minute)).To(Equal(time.Minute))
+		})
+
+		It("should return the duration when set", func() {
+			duration := runner.Duration(time.Second)
+			Expect(duration.OrDefault(time.Minute)).To(Equal(time.Second))
+		})
+	})
+})
@@ -1,30 +1,27 @@
 package runner
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
-	"regexp"
+	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+// defaultDenyEnv are never inherited from the ignition env,
+// the PATH is set by GetPathEnv instead
+var defaultDenyEnv = []string{"PATH", "DEBUG"}
+
 // SetEnv formats the env key / value pair
 func SetEnv(key, value string) string {
 	return fmt.Sprintf("%s=%s", key, value)
 }
 
-// GetEnviron overrides and formats the env
-func GetEnviron(variables ...string) []string {
-	osEnv := os.Environ()
-	newEnv := []string{}
-	for _, env := range osEnv {
-		r := regexp.MustCompile(`(?i)(PATH|DEBUG)\=`)
-		if !r.MatchString(env) {
-			newEnv = append(newEnv, env)
-		}
-	}
-	return append(newEnv, variables...)
-}
-
 // GetPathEnv handles the Path craziness
 func GetPathEnv(binPath string) string {
 	if runtime.GOOS == "darwin" {
@@ -35,3 +32,153 @@ func GetPathEnv(binPath string) string {
 	}
 	return SetEnv("PATH", fmt.Sprintf("%s:%s", os.Getenv("PATH"), binPath))
 }
+
+// FilterEnv returns the env variables matching the allow patterns,
+// or all when there are none, except the ones matching the deny patterns.
+// Patterns are case insensitive names with * wildcards, like NODE_*
+func FilterEnv(environ []string, allow, deny []string) []string {
+	filtered := []string{}
+	for _, env := range environ {
+		key := getEnvKey(env)
+		if len(allow) > 0 && !matchEnvKey(key, allow) {
+			continue
+		}
+		if matchEnvKey(key, deny) {
+			continue
+		}
+		filtered = append(filtered, env)
+	}
+	return filtered
+}
+
+// MergeEnv sets the key / value pairs on the env, replacing existing keys
+func MergeEnv(environ []string, variables ...string) []string {
+	merged := append([]string{}, environ...)
+	for _, variable := range variables {
+		key := getEnvKey(variable)
+		replaced := false
+		for i, env := range merged {
+			if sameEnvKey(getEnvKey(env), key) {
+				merged[i] = variable
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			merged = append(merged, variable)
+		}
+	}
+	return merged
+}
+
+// ExpandEnv replaces $VAR and ${VAR} in the value with the env
+func ExpandEnv(environ []string, value string) string {
+	return os.Expand(value, func(key string) string {
+		for i := len(environ) - 1; i >= 0; i-- {
+			if sameEnvKey(getEnvKey(environ[i]), key) {
+				return strings.SplitN(environ[i], "=", 2)[1]
+			}
+		}
+		return ""
+	})
+}
+
+// ParseEnvFile parses the KEY=VALUE lines of a .env file,
+// skipping comments and an optional export prefix
+func ParseEnvFile(data []byte) ([]string, error) {
+	variables := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		parts := strings.SplitN(line, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" {
+			return nil, fmt.Errorf("invalid env line %v, must be KEY=VALUE", lineNumber)
+		}
+		value, err := unquoteEnvValue(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid env line %v: %v", lineNumber, err)
+		}
+		variables = append(variables, SetEnv(key, value))
+	}
+	return variables, scanner.Err()
+}
+
+// GetEnv returns the env of the connector: the inherited variables,
+// the PATH, the extra variables, the EnvFiles and then the Env,
+// expanding the values of the last two
+func (config *Config) GetEnv(extra ...string) ([]string, error) {
+	inherited := FilterEnv(os.Environ(), config.InheritEnv, append(append([]string{}, defaultDenyEnv...), config.DenyEnv...))
+	env := MergeEnv(inherited, GetPathEnv(config.BinPath))
+	env = MergeEnv(env, extra...)
+	for _, envFile := range config.EnvFiles {
+		if !filepath.IsAbs(envFile) {
+			envFile = filepath.Join(config.Dir, envFile)
+		}
+		data, err := ioutil.ReadFile(envFile)
+		if err != nil {
+			return nil, err
+		}
+		variables, err := ParseEnvFile(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", envFile, err)
+		}
+		for _, variable := range variables {
+			parts := strings.SplitN(variable, "=", 2)
+			env = MergeEnv(env, SetEnv(parts[0], ExpandEnv(env, parts[1])))
+		}
+	}
+	keys := []string{}
+	for key := range config.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		env = MergeEnv(env, SetEnv(key, ExpandEnv(env, config.Env[key])))
+	}
+	return env, nil
+}
+
+func getEnvKey(env string) string {
+	return strings.SplitN(env, "=", 2)[0]
+}
+
+// sameEnvKey compares env keys, case insensitive on windows
+func sameEnvKey(a, b string) bool {
+	if runtime.GOOS == "windows" {
+		return strings.EqualFold(a, b)
+	}
+	return a == b
+}
+
+func matchEnvKey(key string, patterns []string) bool {
+	for _, pattern := range patterns {
+		matched, err := filepath.Match(strings.ToUpper(pattern), strings.ToUpper(key))
+		if err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+func unquoteEnvValue(value string) (string, error) {
+	if len(value) < 2 {
+		return value, nil
+	}
+	if value[0] == '"' && value[len(value)-1] == '"' {
+		return strconv.Unquote(value)
+	}
+	if value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1], nil
+	}
+	if index := strings.Index(value, " #"); index >= 0 {
+		return strings.TrimSpace(value[:index]), nil
+	}
+	return value, nil
+}
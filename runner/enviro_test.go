@@ -0,0 +1,90 @@
+package runner_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Enviro", func() {
+	Describe("->FilterEnv", func() {
+		environ := []string{"PATH=/usr/bin", "DEBUG=*", "MY_DEBUG_PATH=/tmp", "NODE_ENV=production", "HOME=/root"}
+
+		It("should only deny the exact names", func() {
+			filtered := runner.FilterEnv(environ, nil, []string{"PATH", "DEBUG"})
+			Expect(filtered).To(Equal([]string{"MY_DEBUG_PATH=/tmp", "NODE_ENV=production", "HOME=/root"}))
+		})
+
+		It("should only allow the matching names", func() {
+			filtered := runner.FilterEnv(environ, []string{"NODE_*", "home"}, nil)
+			Expect(filtered).To(Equal([]string{"NODE_ENV=production", "HOME=/root"}))
+		})
+	})
+
+	Describe("->MergeEnv", func() {
+		It("should replace existing keys and append new ones", func() {
+			merged := runner.MergeEnv([]string{"A=1", "B=2"}, "B=3", "C=4")
+			Expect(merged).To(Equal([]string{"A=1", "B=3", "C=4"}))
+		})
+	})
+
+	Describe("->ExpandEnv", func() {
+		It("should expand the variables from the env", func() {
+			Expect(runner.ExpandEnv([]string{"HOME=/root"}, "${HOME}/data:$HOME:$MISSING")).To(Equal("/root/data:/root:"))
+		})
+	})
+
+	Describe("->ParseEnvFile", func() {
+		It("should parse the variables", func() {
+			variables, err := runner.ParseEnvFile([]byte("# comment\n\nexport A=1\nB=\"two words\\n\"\nC='$literal'\nD=value # comment\n"))
+			Expect(err).To(BeNil())
+			Expect(variables).To(Equal([]string{"A=1", "B=two words\n", "C=$literal", "D=value"}))
+		})
+
+		It("should return an error for an invalid line", func() {
+			_, err := runner.ParseEnvFile([]byte("A=1\nnot-a-variable\n"))
+			Expect(err).To(MatchError("invalid env line 2, must be KEY=VALUE"))
+		})
+	})
+
+	Describe("->GetEnv", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "enviro-test")
+			ioutil.WriteFile(filepath.Join(dir, ".env"), []byte("DATA_DIR=$CONNECTOR_HOME/data\n"), 0644)
+			os.Setenv("IGNITION_TEST_SECRET", "secret")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+			os.Unsetenv("IGNITION_TEST_SECRET")
+		})
+
+		It("should combine the env files and the env", func() {
+			config := &runner.Config{
+				Dir:      dir,
+				EnvFiles: []string{".env"},
+				Env:      map[string]string{"CACHE_DIR": "${DATA_DIR}/cache"},
+				DenyEnv:  []string{"IGNITION_TEST_*"},
+			}
+			env, err := config.GetEnv("CONNECTOR_HOME=/home/connector")
+			Expect(err).To(BeNil())
+			Expect(env).To(ContainElement("DATA_DIR=/home/connector/data"))
+			Expect(env).To(ContainElement("CACHE_DIR=/home/connector/data/cache"))
+			Expect(env).NotTo(ContainElement("IGNITION_TEST_SECRET=secret"))
+			Expect(env).To(ContainElement(runner.GetPathEnv("")))
+		})
+
+		It("should return an error for a missing env file", func() {
+			config := &runner.Config{Dir: dir, EnvFiles: []string{"missing.env"}}
+			_, err := config.GetEnv()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,36 @@
+package runner
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+)
+
+// getExit returns the exit code, signal and reason of the finished command
+func getExit(cmd *exec.Cmd, waitErr error) *status.Exit {
+	exit := &status.Exit{Code: -1, At: status.Now()}
+	if cmd == nil || cmd.ProcessState == nil {
+		exit.Reason = "exited for an unknown reason"
+		if waitErr != nil {
+			exit.Reason = waitErr.Error()
+		}
+		return exit
+	}
+	if waitStatus, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok {
+		exit.Code = waitStatus.ExitStatus()
+		if waitStatus.Signaled() {
+			exit.Signal = waitStatus.Signal().String()
+		}
+	}
+	exit.Reason = getExitReason(exit)
+	return exit
+}
+
+func getExitReason(exit *status.Exit) string {
+	if exit.Signal != "" {
+		return fmt.Sprintf("terminated by signal %s", exit.Signal)
+	}
+	return fmt.Sprintf("exited with code %v", exit.Code)
+}
@@ -0,0 +1,7 @@
+package runner
+
+// ParseProcStat exposes parseProcStat
+var ParseProcStat = parseProcStat
+
+// ParseOwnCgroup exposes parseOwnCgroup
+var ParseOwnCgroup = parseOwnCgroup
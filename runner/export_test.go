@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"os/exec"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/connector"
+	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+	"github.com/octoblu/go-meshblu-connector-ignition/updateconnector"
+	"github.com/octoblu/process"
+)
+
+func init() {
+	mainLogger = logger.NewFakeMainLogger()
+	setupRetryDelay = 20 * time.Millisecond
+}
+
+// NewTestProgram creates a program with the
+// device, status device and updater of a test
+func NewTestProgram(config *Config, connectorClient connector.Connector, statusClient status.Status, uc updateconnector.UpdateConnector) (*Program, error) {
+	prg, err := NewProgram(config)
+	if err != nil {
+		return nil, err
+	}
+	prg.connector = connectorClient
+	prg.status = statusClient
+	prg.uc = uc
+	prg.rolledBackTag = uc.RolledBackTag()
+	prg.telemetry = newTelemetry(statusClient, "1.2.3")
+	return prg, nil
+}
+
+// Update exposes update
+func (prg *Program) Update() error {
+	return prg.update()
+}
+
+// RecordCrash exposes recordCrash
+func (prg *Program) RecordCrash() {
+	prg.recordCrash()
+}
+
+// ShouldRollback exposes shouldRollback
+func (prg *Program) ShouldRollback() bool {
+	return prg.shouldRollback()
+}
+
+// SetUpdatedAt moves the last update back in time
+func (prg *Program) SetUpdatedAt(updatedAt time.Time) {
+	prg.updateMutex.Lock()
+	defer prg.updateMutex.Unlock()
+	prg.updatedAt = updatedAt
+}
+
+// Background exposes background
+func (prg *Program) Background(cmd *exec.Cmd, cgroupPath string) (*process.Group, error) {
+	return prg.background(cmd, prg.getConfig(), prg.getOptions(), cgroupPath)
+}
+
+// StartRun starts the command as the connector
+// of a new run, like the restart loop does
+func (prg *Program) StartRun(cmd *exec.Cmd) error {
+	prg.mutex.Lock()
+	prg.currentRun = "test-run"
+	prg.mutex.Unlock()
+	cmdGroup, cgroupPath, err := prg.startRun("test-run", cmd, time.Now())
+	if err != nil {
+		return err
+	}
+	go prg.waitForExit("test-run", cmd, cmdGroup, cgroupPath)
+	return nil
+}
+
+// WatchResources exposes watchResources for the run
+func (prg *Program) WatchResources() {
+	prg.watchResources("test-run", prg.cmd.Process.Pid)
+}
+
+// WatchHealth exposes watchHealth for the run
+func (prg *Program) WatchHealth() {
+	prg.watchHealth("test-run")
+}
+
+// HandleExit exposes handleExit
+func (prg *Program) HandleExit(exit *status.Exit) {
+	prg.handleExit(exit)
+}
+
+// EndRun makes the goroutines of the run stop
+func (prg *Program) EndRun() {
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
+	prg.currentRun = ""
+}
+
+// RestartChan receives the restarts of the program
+func (prg *Program) RestartChan() <-chan bool {
+	return prg.restartChan
+}
+
+// CheckForChanges exposes checkForChanges
+func (prg *Program) CheckForChanges() error {
+	return prg.checkForChanges()
+}
+
+// SetErrorReporter replaces the error reporter of the program
+func (prg *Program) SetErrorReporter(errorReporter status.ErrorReporter) {
+	prg.errorReporter = errorReporter
+}
+
+// Telemetry exposes the telemetry of the status device
+type Telemetry struct {
+	*telemetry
+}
+
+// NewTelemetry creates the telemetry of a test
+func NewTelemetry(statusClient status.Status) *Telemetry {
+	return &Telemetry{newTelemetry(statusClient, "1.2.3")}
+}
+
+// Update exposes update
+func (t *Telemetry) Update(change func(ignition *status.Ignition)) {
+	t.update(change)
+}
+
+// SetState exposes setState
+func (t *Telemetry) SetState(state string) {
+	t.setState(state)
+}
+
+// Close exposes close
+func (t *Telemetry) Close() {
+	t.close()
+}
+
+// NewTestClient creates a client setting up its
+// programs with createProgram instead of meshblu
+func NewTestClient(config *Config, createProgram func(config *Config) (*Program, error)) *Client {
+	client := New(config, "1.2.3").(*Client)
+	client.createProgram = createProgram
+	return client
+}
+
+// StartPrograms starts the programs like the service does
+func (client *Client) StartPrograms() error {
+	configs, err := client.config.GetConnectorConfigs()
+	if err != nil {
+		return err
+	}
+	err = (&programGroup{client}).Start(nil)
+	if err != nil {
+		return err
+	}
+	return client.addPrograms(configs)
+}
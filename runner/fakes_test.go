@@ -0,0 +1,241 @@
+package runner_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+
+	. "github.com/onsi/gomega"
+)
+
+// newTestConfig returns the config of a connector in a new
+// temp dir, logging to files in it
+func newTestConfig(prefix string) *runner.Config {
+	dir, err := ioutil.TempDir("", prefix)
+	Expect(err).NotTo(HaveOccurred())
+	return &runner.Config{
+		ServiceName: "MeshbluConnector-some-uuid",
+		Dir:         dir,
+		Stdout:      filepath.Join(dir, "connector.log"),
+		Stderr:      filepath.Join(dir, "connector-error.log"),
+	}
+}
+
+// fakeConnector is a device at the version, changing
+// when the test sets a new version or stopped
+type fakeConnector struct {
+	mutex          sync.Mutex
+	version        string
+	stopped        bool
+	fetchedVersion string
+	fetchedStopped bool
+	versionChanged bool
+	stopChanged    bool
+}
+
+func newFakeConnector(version string) *fakeConnector {
+	return &fakeConnector{version: version, fetchedVersion: version}
+}
+
+func (c *fakeConnector) set(version string, stopped bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.version = version
+	c.stopped = stopped
+}
+
+func (c *fakeConnector) Fetch() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.versionChanged = c.version != c.fetchedVersion
+	c.stopChanged = c.stopped != c.fetchedStopped
+	c.fetchedVersion = c.version
+	c.fetchedStopped = c.stopped
+	return nil
+}
+
+func (c *fakeConnector) DidVersionChange() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.versionChanged
+}
+
+func (c *fakeConnector) DidStopChange() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stopChanged
+}
+
+func (c *fakeConnector) StatusUUID() string {
+	return "status-uuid"
+}
+
+func (c *fakeConnector) Stopped() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.fetchedStopped
+}
+
+func (c *fakeConnector) Version() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.fetchedVersion
+}
+
+func (c *fakeConnector) VersionWithV() string {
+	return "v" + c.Version()
+}
+
+// fakeStatus records the updates of the status device and the
+// backoffs it went through, the ignition updates wait for the
+// block when there is one
+type fakeStatus struct {
+	mutex           sync.Mutex
+	stopped         bool
+	failed          []string
+	ignition        status.Ignition
+	ignitionUpdates int
+	backoffs        []int64
+	block           chan bool
+}
+
+func (s *fakeStatus) Fetch() error {
+	return nil
+}
+
+func (s *fakeStatus) UpdateErrorEntries(entries []status.ErrorEntry) error {
+	return nil
+}
+
+func (s *fakeStatus) UpdateStopped(stopped bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.stopped = stopped
+	return nil
+}
+
+func (s *fakeStatus) UpdateFailed(tag string, updateErr error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failed = append(s.failed, tag+": "+updateErr.Error())
+	return nil
+}
+
+func (s *fakeStatus) UpdateIgnition(ignition *status.Ignition) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if ignition.State == status.StateBackingOff && s.ignition.State != status.StateBackingOff {
+		s.backoffs = append(s.backoffs, ignition.NextBackoff)
+	}
+	s.ignition = *ignition
+	s.ignitionUpdates++
+	return nil
+}
+
+func (s *fakeStatus) ResetErrors() error {
+	return nil
+}
+
+func (s *fakeStatus) getIgnition() status.Ignition {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.ignition
+}
+
+func (s *fakeStatus) getStopped() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.stopped
+}
+
+func (s *fakeStatus) getBackoffs() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]int64{}, s.backoffs...)
+}
+
+func (s *fakeStatus) getIgnitionUpdates() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.ignitionUpdates
+}
+
+// fakeUpdateConnector records the updates and rollbacks
+type fakeUpdateConnector struct {
+	mutex      sync.Mutex
+	tag        string
+	doErr      error
+	updates    []string
+	rollbacks  int
+	rolledBack string
+}
+
+func (u *fakeUpdateConnector) NeedsUpdate(tag string) (bool, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return tag != u.tag, nil
+}
+
+func (u *fakeUpdateConnector) Do(tag string) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.doErr != nil {
+		return u.doErr
+	}
+	u.updates = append(u.updates, tag)
+	u.tag = tag
+	return nil
+}
+
+func (u *fakeUpdateConnector) Rollback() error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.rollbacks++
+	u.rolledBack = u.tag
+	return nil
+}
+
+func (u *fakeUpdateConnector) RolledBackTag() string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.rolledBack
+}
+
+func (u *fakeUpdateConnector) ClearRolledBack() error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	u.rolledBack = ""
+	return nil
+}
+
+// fakeReporter counts the resets of the reported errors
+type fakeReporter struct {
+	mutex  sync.Mutex
+	resets int
+}
+
+func (r *fakeReporter) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func (r *fakeReporter) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.resets++
+}
+
+func (r *fakeReporter) Close() error {
+	return nil
+}
+
+func (r *fakeReporter) getResets() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.resets
+}
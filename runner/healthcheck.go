@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/health"
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+)
+
+// HealthCheck defines a liveness check of the connector
+type HealthCheck struct {
+	// Type is one of http, tcp, heartbeat or command
+	Type string
+
+	// URL is requested by the http check
+	URL string
+
+	// Address is the host:port connected to by the tcp check
+	Address string
+
+	// Path is the file the connector touches for the heartbeat check,
+	// relative to the connector Dir
+	Path string
+
+	// Command and Args are run in the connector Dir by the command check
+	Command string
+	Args    []string
+
+	// Interval is the time between checks, defaults to 30s
+	Interval Duration
+
+	// Timeout limits each check, defaults to 5s
+	Timeout Duration
+
+	// StartPeriod is the time after the connector starts
+	// before the first check, defaults to the Interval
+	StartPeriod Duration
+
+	// FailureThreshold is the number of consecutive failures
+	// that restart the connector, defaults to 3
+	FailureThreshold int
+}
+
+// GetChecker returns the health.Checker for the check type
+func (check *HealthCheck) GetChecker(dir string) (health.Checker, error) {
+	timeout := check.Timeout.OrDefault(5 * time.Second)
+	switch check.Type {
+	case "http":
+		return health.NewHTTP(check.URL, timeout), nil
+	case "tcp":
+		return health.NewTCP(check.Address, timeout), nil
+	case "heartbeat":
+		path := check.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		return health.NewHeartbeat(path), nil
+	case "command":
+		return health.NewCommand(dir, check.Command, check.Args, timeout), nil
+	}
+	return nil, fmt.Errorf("invalid health check type %s, must be one of http, tcp, heartbeat or command", check.Type)
+}
+
+// GetFailureThreshold returns the number of failures that restart the connector
+func (check *HealthCheck) GetFailureThreshold() int {
+	if check.FailureThreshold <= 0 {
+		return 3
+	}
+	return check.FailureThreshold
+}
+
+// watchHealth runs the health checks for as long as the run is current
+func (prg *Program) watchHealth(currentRun string) {
+	config := prg.getConfig()
+	for i := range config.HealthChecks {
+		check := &config.HealthChecks[i]
+		checker, err := check.GetChecker(config.Dir)
+		if err != nil {
+			mainLogger.Error("program.watchHealth", "Error creating health check", err)
+			continue
+		}
+		go prg.runHealthCheck(currentRun, check, checker)
+	}
+}
+
+func (prg *Program) runHealthCheck(currentRun string, check *HealthCheck, checker health.Checker) {
+	interval := check.Interval.OrDefault(30 * time.Second)
+	if !prg.wait(check.StartPeriod.OrDefault(interval)) {
+		return
+	}
+	failures := 0
+	for prg.isCurrentRun(currentRun) {
+		err := checker.Check()
+		if !prg.isCurrentRun(currentRun) {
+			return
+		}
+		if err == nil {
+			failures = 0
+			if !prg.wait(interval) {
+				return
+			}
+			continue
+		}
+		failures++
+		mainLogger.Error("program.runHealthCheck", fmt.Sprintf("%s health check failed (%v/%v)", check.Type, failures, check.GetFailureThreshold()), err)
+		if failures >= check.GetFailureThreshold() {
+			prg.unhealthy(currentRun, check.Type, err)
+			return
+		}
+		if !prg.wait(interval) {
+			return
+		}
+	}
+}
+
+// unhealthy terminates the connector of the run after a health check
+// failed too often, its exit counts as a crash even when the connector
+// exits cleanly, going through the restart policy and the MaxRestarts
+// circuit breaker like any other crash
+func (prg *Program) unhealthy(currentRun, checkType string, checkErr error) {
+	prg.mutex.Lock()
+	cmdGroup := prg.cmdGroup
+	isCurrentRun := currentRun == prg.currentRun
+	if isCurrentRun {
+		prg.unhealthyRun = currentRun
+	}
+	prg.mutex.Unlock()
+	if !isCurrentRun {
+		return
+	}
+	mainLogger.Info("program.unhealthy", "connector is unhealthy, terminating it")
+	prg.telemetry.update(func(ignition *status.Ignition) {
+		ignition.LastUnhealthy = &status.Unhealthy{
+			Check: checkType,
+			Error: checkErr.Error(),
+			At:    status.Now(),
+		}
+	})
+	if cmdGroup == nil {
+		return
+	}
+	err := cmdGroup.Terminate(30 * time.Second)
+	if _, isExitError := err.(*exec.ExitError); err != nil && !isExitError {
+		mainLogger.Error("program.unhealthy", "Error terminating the connector", err)
+	}
+}
+
+// isUnhealthyRun returns true once the connector of the run was terminated as unhealthy
+func (prg *Program) isUnhealthyRun(currentRun string) bool {
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
+	return currentRun == prg.unhealthyRun
+}
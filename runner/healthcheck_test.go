@@ -0,0 +1,105 @@
+package runner_test
+
+import (
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HealthCheck", func() {
+	Describe("when the connector is unhealthy", func() {
+		var dir string
+		var config *runner.Config
+		var statusDevice *fakeStatus
+		var prg *runner.Program
+		var cmd *exec.Cmd
+
+		start := func() {
+			var err error
+			prg, err = runner.NewTestProgram(config, newFakeConnector("1.0.0"), statusDevice, &fakeUpdateConnector{})
+			Expect(err).NotTo(HaveOccurred())
+			cmd = exec.Command("/bin/sh", "-c", "exec sleep 10")
+			Expect(prg.StartRun(cmd)).To(Succeed())
+		}
+
+		state := func() string {
+			return statusDevice.getIgnition().State
+		}
+
+		BeforeEach(func() {
+			config = newTestConfig("healthcheck")
+			dir = config.Dir
+			config.HealthChecks = []runner.HealthCheck{{
+				Type:             "command",
+				Command:          "false",
+				Interval:         runner.Duration(50 * time.Millisecond),
+				FailureThreshold: 2,
+			}}
+			statusDevice = &fakeStatus{}
+		})
+
+		AfterEach(func() {
+			prg.EndRun()
+			cmd.Process.Kill()
+			os.RemoveAll(dir)
+		})
+
+		It("should terminate and restart it", func() {
+			start()
+			prg.WatchHealth()
+			Eventually(prg.RestartChan(), 2*time.Second).Should(Receive(BeTrue()))
+			Eventually(state).Should(Equal(status.StateCrashed))
+			Eventually(statusDevice.getIgnition).Should(WithTransform(func(ignition status.Ignition) string {
+				if ignition.LastUnhealthy == nil {
+					return ""
+				}
+				return ignition.LastUnhealthy.Check
+			}, Equal("command")))
+		})
+
+		Describe("when it exits cleanly on SIGTERM with the on-failure restart policy", func() {
+			It("should restart it as crashed", func() {
+				config.RestartPolicy = runner.RestartOnFailure
+				var err error
+				prg, err = runner.NewTestProgram(config, newFakeConnector("1.0.0"), statusDevice, &fakeUpdateConnector{})
+				Expect(err).NotTo(HaveOccurred())
+				cmd = exec.Command("/bin/sh", "-c", "trap 'exit 0' TERM; while true; do sleep 0.05; done")
+				Expect(prg.StartRun(cmd)).To(Succeed())
+				prg.WatchHealth()
+				Eventually(prg.RestartChan(), 2*time.Second).Should(Receive(BeTrue()))
+				Eventually(state).Should(Equal(status.StateCrashed))
+				Expect(statusDevice.getIgnition().LastExit.Code).To(Equal(0))
+				Expect(statusDevice.getIgnition().LastExit.Unhealthy).To(BeTrue())
+			})
+		})
+
+		Describe("with the never restart policy", func() {
+			It("should terminate it and keep it down", func() {
+				config.RestartPolicy = runner.RestartNever
+				start()
+				prg.WatchHealth()
+				Eventually(state, 2*time.Second).Should(Equal(status.StateCrashed))
+				Consistently(prg.RestartChan(), 200*time.Millisecond).ShouldNot(Receive())
+			})
+		})
+
+		Describe("when it restarted more than MaxRestarts", func() {
+			It("should trip the circuit breaker", func() {
+				config.MaxRestarts = 1
+				start()
+				prg.HandleExit(&status.Exit{Code: 1, Reason: "exited with code 1"})
+				Expect(prg.RestartChan()).To(Receive(BeTrue()))
+
+				prg.WatchHealth()
+				Eventually(state, 2*time.Second).Should(Equal(status.StateFailed))
+				Consistently(prg.RestartChan(), 200*time.Millisecond).ShouldNot(Receive())
+			})
+		})
+	})
+})
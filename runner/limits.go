@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// Limits defines the resource limits of the connector process,
+// only applied on Linux. The rlimits are set by the connector process
+// before it execs the connector, a connector running as another User
+// cannot raise them above the hard limits of the ignition
+type Limits struct {
+	// MemoryBytes is the cgroup memory.max of the connector
+	MemoryBytes int64
+
+	// CPUPercent is the cgroup cpu.max of the connector, 100 is one core
+	CPUPercent int
+
+	// Processes is the process count rlimit and cgroup pids.max
+	Processes uint64
+
+	// OpenFiles is the open file rlimit
+	OpenFiles uint64
+
+	// CPUSeconds is the cpu time rlimit
+	CPUSeconds uint64
+
+	// AddressSpaceBytes is the virtual memory rlimit,
+	// node reserves a lot of it so prefer MemoryBytes
+	AddressSpaceBytes uint64
+
+	// CgroupPath is the cgroup v2 directory of the connector, its parent
+	// must have the controllers enabled. Defaults to the ServiceName in the
+	// cgroup systemd delegated to the ignition, the ignition moves itself
+	// into an ignition cgroup next to it
+	CgroupPath string
+
+	// SoftMemoryBytes and SoftCPUPercent restart the connector when
+	// it uses more for longer than the SoftLimitDuration
+	SoftMemoryBytes int64
+	SoftCPUPercent  float64
+
+	// SoftLimitDuration defaults to 1m
+	SoftLimitDuration Duration
+
+	// WatchInterval is the time between the memory
+	// and cpu samples, defaults to 10s
+	WatchInterval Duration
+}
+
+// HasCgroupLimits returns true if a cgroup limit is set
+func (limits *Limits) HasCgroupLimits() bool {
+	return limits.MemoryBytes > 0 || limits.CPUPercent > 0 || limits.Processes > 0
+}
+
+// HasSoftLimits returns true if the watchdog should run
+func (limits *Limits) HasSoftLimits() bool {
+	return limits.SoftMemoryBytes > 0 || limits.SoftCPUPercent > 0
+}
+
+// GetCgroupPath returns the cgroup v2 directory of the connector
+// in the delegated cgroup of the ignition
+func (limits *Limits) GetCgroupPath(delegatedCgroup, serviceName string) string {
+	if limits.CgroupPath != "" {
+		return limits.CgroupPath
+	}
+	return filepath.Join(delegatedCgroup, serviceName)
+}
+
+// joinCgroup moves the process into the cgroup
+func joinCgroup(cgroupPath string, pid int) error {
+	return ioutil.WriteFile(filepath.Join(cgroupPath, "cgroup.procs"), []byte(strconv.Itoa(pid)), 0644)
+}
+
+// GetCPUMax returns the cgroup cpu.max value of the CPUPercent
+func (limits *Limits) GetCPUMax() string {
+	period := 100000
+	return fmt.Sprintf("%v %v", limits.CPUPercent*period/100, period)
+}
+
+// GetSoftLimitDuration returns how long the soft limits may be exceeded
+func (limits *Limits) GetSoftLimitDuration() time.Duration {
+	return limits.SoftLimitDuration.OrDefault(time.Minute)
+}
+
+// GetWatchInterval returns the time between the watchdog samples
+func (limits *Limits) GetWatchInterval() time.Duration {
+	return limits.WatchInterval.OrDefault(10 * time.Second)
+}
@@ -0,0 +1,16 @@
+package runner
+
+func (limits *Limits) getRlimits() []childRlimit {
+	return nil
+}
+
+func prepareCgroup(config *Config) (string, error) {
+	if config.Limits != (Limits{}) {
+		mainLogger.Info("program.prepareCgroup", "resource limits are only supported on linux")
+	}
+	return "", nil
+}
+
+func removeCgroup(cgroupPath string) {}
+
+func (prg *Program) watchResources(currentRun string, pid int) {}
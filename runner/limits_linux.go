@@ -0,0 +1,239 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// ignitionCgroupName is the leaf cgroup the ignition moves itself into,
+// a cgroup with processes cannot enable controllers for its children
+const ignitionCgroupName = "ignition"
+
+// cgroupMutex serializes the delegation between the connectors
+var cgroupMutex sync.Mutex
+
+// rlimitNproc is RLIMIT_NPROC, missing from the syscall package
+const rlimitNproc = 6
+
+// clockTicks is the USER_HZ of the /proc cpu times
+const clockTicks = 100
+
+// getRlimits returns the rlimits the connector process sets
+// on itself before it execs the connector
+func (limits *Limits) getRlimits() []childRlimit {
+	rlimits := []childRlimit{}
+	for _, rlimit := range []childRlimit{
+		{syscall.RLIMIT_NOFILE, limits.OpenFiles},
+		{syscall.RLIMIT_CPU, limits.CPUSeconds},
+		{syscall.RLIMIT_AS, limits.AddressSpaceBytes},
+		{rlimitNproc, limits.Processes},
+	} {
+		if rlimit.Value > 0 {
+			rlimits = append(rlimits, rlimit)
+		}
+	}
+	return rlimits
+}
+
+// prepareCgroup creates the cgroup of the connector with its limits
+// when cgroup v2 is available, the connector joins it before the exec.
+// Returns "" when there are no cgroup limits
+func prepareCgroup(config *Config) (string, error) {
+	limits := &config.Limits
+	if !limits.HasCgroupLimits() {
+		return "", nil
+	}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("cgroup v2 is not available")
+	}
+	delegatedCgroup := ""
+	if limits.CgroupPath == "" {
+		var err error
+		delegatedCgroup, err = delegateCgroup()
+		if err != nil {
+			return "", err
+		}
+	}
+	cgroupPath := limits.GetCgroupPath(delegatedCgroup, config.ServiceName)
+	err := os.MkdirAll(cgroupPath, 0755)
+	if err != nil {
+		return "", err
+	}
+	values := map[string]string{}
+	if limits.MemoryBytes > 0 {
+		values["memory.max"] = strconv.FormatInt(limits.MemoryBytes, 10)
+	}
+	if limits.CPUPercent > 0 {
+		values["cpu.max"] = limits.GetCPUMax()
+	}
+	if limits.Processes > 0 {
+		values["pids.max"] = strconv.FormatUint(limits.Processes, 10)
+	}
+	for name, value := range values {
+		err = ioutil.WriteFile(filepath.Join(cgroupPath, name), []byte(value), 0644)
+		if err != nil {
+			return "", err
+		}
+	}
+	return cgroupPath, nil
+}
+
+// removeCgroup removes the cgroup once the connector exited, it
+// is kept while processes the connector left behind are in it
+func removeCgroup(cgroupPath string) {
+	if cgroupPath == "" {
+		return
+	}
+	err := os.Remove(cgroupPath)
+	if err != nil && !os.IsNotExist(err) {
+		mainLogger.Error("program.removeCgroup", "Error removing the cgroup", err)
+	}
+}
+
+// delegateCgroup returns the cgroup systemd delegated to the ignition,
+// moving the processes in it into the ignition cgroup the first time
+// so the controllers can be enabled for the connector cgroups
+func delegateCgroup() (string, error) {
+	cgroupMutex.Lock()
+	defer cgroupMutex.Unlock()
+	data, err := ioutil.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	ownCgroup, err := parseOwnCgroup(string(data))
+	if err != nil {
+		return "", err
+	}
+	if filepath.Base(ownCgroup) == ignitionCgroupName {
+		return filepath.Dir(ownCgroup), nil
+	}
+	if ownCgroup == cgroupRoot {
+		return "", fmt.Errorf("the ignition is in the root cgroup, set the CgroupPath of the Limits")
+	}
+	ignitionCgroup := filepath.Join(ownCgroup, ignitionCgroupName)
+	err = os.MkdirAll(ignitionCgroup, 0755)
+	if err != nil {
+		return "", err
+	}
+	procs, err := ioutil.ReadFile(filepath.Join(ownCgroup, "cgroup.procs"))
+	if err != nil {
+		return "", err
+	}
+	for _, field := range strings.Fields(string(procs)) {
+		pid, err := strconv.Atoi(field)
+		if err != nil {
+			continue
+		}
+		err = joinCgroup(ignitionCgroup, pid)
+		if err != nil && pid == os.Getpid() {
+			return "", err
+		}
+	}
+	err = ioutil.WriteFile(filepath.Join(ownCgroup, "cgroup.subtree_control"), []byte("+memory +cpu +pids"), 0644)
+	if err != nil {
+		return "", err
+	}
+	return ownCgroup, nil
+}
+
+// parseOwnCgroup returns the cgroup v2 directory of a /proc/<pid>/cgroup
+func parseOwnCgroup(data string) (string, error) {
+	for _, line := range strings.Split(data, "\n") {
+		if strings.HasPrefix(line, "0::") {
+			return filepath.Join(cgroupRoot, strings.TrimPrefix(line, "0::")), nil
+		}
+	}
+	return "", fmt.Errorf("cgroup v2 is not available")
+}
+
+// watchResources samples the memory and cpu of the connector from /proc,
+// restarting it when a soft limit is exceeded for too long
+func (prg *Program) watchResources(currentRun string, pid int) {
+	limits := &prg.getConfig().Limits
+	if !limits.HasSoftLimits() {
+		return
+	}
+	go func() {
+		var exceededSince time.Time
+		lastTicks, lastSampled := uint64(0), time.Now()
+		for {
+			if !prg.wait(limits.GetWatchInterval()) || !prg.isCurrentRun(currentRun) {
+				return
+			}
+			memory, ticks, err := readProcStats(pid)
+			if err != nil {
+				mainLogger.Error("program.watchResources", "Error reading process stats", err)
+				return
+			}
+			now := time.Now()
+			cpuPercent := float64(0)
+			if lastTicks > 0 {
+				cpuPercent = float64(ticks-lastTicks) / clockTicks / now.Sub(lastSampled).Seconds() * 100
+			}
+			lastTicks, lastSampled = ticks, now
+
+			exceeded := ""
+			if limits.SoftMemoryBytes > 0 && memory > limits.SoftMemoryBytes {
+				exceeded = fmt.Sprintf("memory %v bytes over the soft limit of %v bytes", memory, limits.SoftMemoryBytes)
+			} else if limits.SoftCPUPercent > 0 && cpuPercent > limits.SoftCPUPercent {
+				exceeded = fmt.Sprintf("cpu %.1f%% over the soft limit of %.1f%%", cpuPercent, limits.SoftCPUPercent)
+			}
+			if exceeded == "" {
+				exceededSince = time.Time{}
+				continue
+			}
+			if exceededSince.IsZero() {
+				exceededSince = now
+			}
+			if now.Sub(exceededSince) < limits.GetSoftLimitDuration() {
+				continue
+			}
+			prg.unhealthy(currentRun, "resources", fmt.Errorf("%s for %v", exceeded, limits.GetSoftLimitDuration()))
+			return
+		}
+	}()
+}
+
+// readProcStats returns the resident memory in bytes
+// and the cpu time in clock ticks of the process
+func readProcStats(pid int) (int64, uint64, error) {
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%v/stat", pid))
+	if err != nil {
+		return 0, 0, err
+	}
+	return parseProcStat(string(stat))
+}
+
+func parseProcStat(stat string) (int64, uint64, error) {
+	// the command name may contain spaces, the fields start after it
+	end := strings.LastIndex(stat, ")")
+	if end < 0 {
+		return 0, 0, fmt.Errorf("invalid /proc stat")
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return 0, 0, fmt.Errorf("invalid /proc stat")
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	rssPages, err := strconv.ParseInt(fields[21], 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return rssPages * int64(os.Getpagesize()), utime + stime, nil
+}
@@ -0,0 +1,120 @@
+package runner_test
+
+import (
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limits", func() {
+	Describe("->ParseProcStat", func() {
+		It("should return the resident memory and cpu ticks", func() {
+			stat := "1234 (node) S 1 1234 1234 0 -1 4194560 1000 0 0 0 150 50 0 0 20 0 11 0 100 1000000000 2500 18446744073709551615 1 1 0 0 0 0 0 4096 16898 0 0 0 17 0 0 0 0 0 0"
+			memory, ticks, err := runner.ParseProcStat(stat)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(memory).To(Equal(int64(2500 * os.Getpagesize())))
+			Expect(ticks).To(Equal(uint64(200)))
+		})
+
+		It("should handle a command name with spaces and parens", func() {
+			stat := "1234 (my (node) app) R 1 1234 1234 0 -1 4194560 1000 0 0 0 7 3 0 0 20 0 11 0 100 1000000000 10 18446744073709551615"
+			memory, ticks, err := runner.ParseProcStat(stat)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(memory).To(Equal(int64(10 * os.Getpagesize())))
+			Expect(ticks).To(Equal(uint64(10)))
+		})
+
+		It("should reject a truncated stat", func() {
+			_, _, err := runner.ParseProcStat("1234 (node) S 1 1234")
+			Expect(err).To(MatchError("invalid /proc stat"))
+		})
+
+		It("should reject a stat without a command name", func() {
+			_, _, err := runner.ParseProcStat("garbage")
+			Expect(err).To(MatchError("invalid /proc stat"))
+		})
+	})
+
+	Describe("->ParseOwnCgroup", func() {
+		It("should return the cgroup v2 directory", func() {
+			cgroup, err := runner.ParseOwnCgroup("0::/system.slice/connectors.service\n")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cgroup).To(Equal("/sys/fs/cgroup/system.slice/connectors.service"))
+		})
+
+		It("should reject a cgroup v1 hierarchy", func() {
+			_, err := runner.ParseOwnCgroup("12:pids:/system.slice/connectors.service\n")
+			Expect(err).To(MatchError("cgroup v2 is not available"))
+		})
+	})
+
+	Describe("the watchdog", func() {
+		var dir string
+		var config *runner.Config
+		var prg *runner.Program
+		var cmd *exec.Cmd
+
+		watch := func(script string) {
+			var err error
+			prg, err = runner.NewTestProgram(config, newFakeConnector("1.0.0"), &fakeStatus{}, &fakeUpdateConnector{})
+			Expect(err).NotTo(HaveOccurred())
+			cmd = exec.Command("/bin/sh", "-c", script)
+			Expect(prg.StartRun(cmd)).To(Succeed())
+			prg.WatchResources()
+		}
+
+		BeforeEach(func() {
+			config = newTestConfig("limits")
+			dir = config.Dir
+			config.Limits = runner.Limits{
+				SoftLimitDuration: runner.Duration(200 * time.Millisecond),
+				WatchInterval:     runner.Duration(50 * time.Millisecond),
+			}
+		})
+
+		AfterEach(func() {
+			prg.EndRun()
+			cmd.Process.Kill()
+			os.RemoveAll(dir)
+		})
+
+		Describe("when the connector uses more memory than the soft limit", func() {
+			It("should restart it", func() {
+				config.Limits.SoftMemoryBytes = 1
+				watch("exec sleep 10")
+				Eventually(prg.RestartChan()).Should(Receive(BeTrue()))
+			})
+		})
+
+		Describe("when the connector uses more cpu than the soft limit", func() {
+			It("should restart it", func() {
+				config.Limits.SoftCPUPercent = 10
+				watch("while :; do :; done")
+				Eventually(prg.RestartChan(), 2*time.Second).Should(Receive(BeTrue()))
+			})
+		})
+
+		Describe("when the connector stays under the soft limits", func() {
+			It("should keep it running", func() {
+				config.Limits.SoftMemoryBytes = 1 << 40
+				config.Limits.SoftCPUPercent = 90
+				watch("exec sleep 10")
+				Consistently(prg.RestartChan(), 500*time.Millisecond).ShouldNot(Receive())
+			})
+		})
+
+		Describe("when the run is over", func() {
+			It("should stop watching", func() {
+				config.Limits.SoftMemoryBytes = 1
+				watch("exec sleep 10")
+				prg.EndRun()
+				Consistently(prg.RestartChan(), 500*time.Millisecond).ShouldNot(Receive())
+			})
+		})
+	})
+})
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/octoblu/go-meshblu/config"
+)
+
+// MeshbluConfigName is the connector credentials file in the connector Dir
+const MeshbluConfigName = "meshblu.json"
+
+// meshbluCredentials defines the server and device of the connector
+type meshbluCredentials struct {
+	uri, uuid, token string
+}
+
+// getMeshbluCredentials reads the meshblu.json and resolves
+// its server like meshblu.NewClient
+func (cfg *Config) getMeshbluCredentials(secretValues map[string]string) (*meshbluCredentials, error) {
+	meshbluConfig, err := cfg.readMeshbluConfig(secretValues)
+	if err != nil {
+		return nil, fmt.Errorf("%s %v", MeshbluConfigName, err)
+	}
+	err = checkMeshbluDevice(meshbluConfig)
+	if err != nil {
+		return nil, fmt.Errorf("%s %v", MeshbluConfigName, err)
+	}
+	uri, err := meshbluURI(meshbluConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &meshbluCredentials{uri, meshbluConfig.UUID(), meshbluConfig.Token()}, nil
+}
+
+// readMeshbluConfig reads the meshblu.json from the secret store,
+// or from the connector Dir when the store is disabled
+func (cfg *Config) readMeshbluConfig(secretValues map[string]string) (*config.Config, error) {
+	data, ok := secretValues[MeshbluConfigName]
+	if ok {
+		return readMeshbluConfigData([]byte(data))
+	}
+	if cfg.SecretsMode != "" {
+		return nil, fmt.Errorf("is missing from the secret store, run the secrets import command")
+	}
+	return config.ReadFromConfig(filepath.Join(cfg.Dir, MeshbluConfigName))
+}
+
+// checkMeshbluDevice returns an error when the
+// meshblu.json is missing the device credentials
+func checkMeshbluDevice(meshbluConfig *config.Config) error {
+	if meshbluConfig.UUID() == "" || meshbluConfig.Token() == "" {
+		return fmt.Errorf("must have a uuid and token")
+	}
+	return nil
+}
+
+// meshbluURI returns the server of the meshblu.json,
+// looking up the SRV record when resolveSrv is set
+func meshbluURI(meshbluConfig *config.Config) (string, error) {
+	if !meshbluConfig.ResolveSRV() {
+		return meshbluConfig.ToURL()
+	}
+	protocol := "http"
+	if meshbluConfig.Secure() {
+		protocol = "https"
+	}
+	_, addrs, err := net.LookupSRV("meshblu", protocol, meshbluConfig.Domain())
+	if err != nil {
+		return "", err
+	}
+	if len(addrs) == 0 {
+		return "", fmt.Errorf("Received an empty list of addresses attempting to resolve SRV")
+	}
+	hostname := strings.TrimSuffix(addrs[0].Target, ".")
+	uri, err := config.NewURL(hostname, int(addrs[0].Port))
+	if err != nil {
+		return "", err
+	}
+	return uri.String(), nil
+}
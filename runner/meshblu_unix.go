@@ -0,0 +1,26 @@
+// +build !windows
+
+package runner
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/octoblu/go-meshblu/config"
+)
+
+// readMeshbluConfigData reads a meshblu.json held in memory through
+// a pipe, go-meshblu only reads configs from a path and the secrets
+// must not be written to the disk
+func readMeshbluConfigData(data []byte) (*config.Config, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	go func() {
+		writer.Write(data)
+		writer.Close()
+	}()
+	return config.ReadFromConfig(fmt.Sprintf("/dev/fd/%d", reader.Fd()))
+}
@@ -0,0 +1,13 @@
+package runner
+
+import (
+	"fmt"
+
+	"github.com/octoblu/go-meshblu/config"
+)
+
+// readMeshbluConfigData is not supported, the secret
+// store cannot hand the secrets over on windows
+func readMeshbluConfigData(data []byte) (*config.Config, error) {
+	return nil, fmt.Errorf("the secret store is not supported on windows")
+}
@@ -1,9 +1,15 @@
 package runner
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
-	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/jpillora/backoff"
@@ -11,29 +17,67 @@ import (
 	"github.com/octoblu/go-meshblu-connector-ignition/connector"
 	"github.com/octoblu/go-meshblu-connector-ignition/interval"
 	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+	"github.com/octoblu/go-meshblu-connector-ignition/secrets"
 	"github.com/octoblu/go-meshblu-connector-ignition/status"
+	"github.com/octoblu/go-meshblu-connector-ignition/subscription"
 	"github.com/octoblu/go-meshblu-connector-ignition/updateconnector"
+	"github.com/octoblu/go-meshblu/http/meshblu"
 	"github.com/octoblu/process"
 	uuid "github.com/satori/go.uuid"
+	"github.com/uber-go/atomic"
 )
 
 // Program inteface that is real
 type Program struct {
+	mutex         sync.Mutex
 	config        *Config
+	options       *programOptions
 	cmd           *exec.Cmd
 	cmdGroup      *process.Group
 	connector     connector.Connector
 	currentRun    string
+	unhealthyRun  string
 	status        status.Status
+	errorReporter status.ErrorReporter
+	telemetry     *telemetry
 	uc            updateconnector.UpdateConnector
 	boff          *backoff.Backoff
 	timeStarted   time.Time
 	errLog        logger.Logger
 	outLog        logger.Logger
 	interval      interval.Interval
-	started       bool
-	shouldRestart bool
+	stream        subscription.Stream
+	streaming     *atomic.Bool
+	changesMutex  sync.Mutex
+	restarts      []time.Time
+	restartMutex  sync.Mutex
+	meshbluClient meshblu.Meshblu
+	deviceUUID    string
+	retiredLogs   []logger.Logger
+	started       *atomic.Bool
+	stopped       *atomic.Bool
+	localStopped  *atomic.Bool
+	running       *atomic.Bool
+	updatedAt     time.Time
+	updatedTag    string
+	updateCrashes int
+	updateMutex   sync.Mutex
+	rolledBackTag string
 	restartChan   chan bool
+	done          chan bool
+}
+
+// Status defines the current state of the program
+type Status struct {
+	Name         string    `json:"name"`
+	State        string    `json:"state"`
+	RestartCount int       `json:"restartCount"`
+	Running      bool      `json:"running"`
+	Stopped      bool      `json:"stopped"`
+	LocalStopped bool      `json:"localStopped"`
+	PID          int       `json:"pid,omitempty"`
+	Version      string    `json:"version"`
+	StartedAt    time.Time `json:"startedAt"`
 }
 
 // NewProgram creates a new program cient
@@ -42,79 +86,327 @@ func NewProgram(config *Config) (*Program, error) {
 		mainLogger = logger.GetMainLogger()
 	}
 
-	outLog, err := logger.NewLogger(config.Stdout, false)
+	outLog, err := logger.NewLogger(config.Stdout, false, config.GetLogOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	errLog, err := logger.NewLogger(config.Stderr, true, config.GetLogOptions())
 	if err != nil {
+		outLog.Close()
 		return nil, err
 	}
 
-	errLog, err := logger.NewLogger(config.Stderr, true)
+	options, err := getProgramOptions(config)
 	if err != nil {
+		outLog.Close()
+		errLog.Close()
 		return nil, err
 	}
 
 	return &Program{
-		config: config,
-		boff: &backoff.Backoff{
-			Min: time.Second,
-			Max: time.Minute,
-		},
-		errLog:        errLog,
-		outLog:        outLog,
-		started:       false,
-		shouldRestart: true,
-		restartChan:   make(chan bool, 1),
+		config:       config,
+		options:      options,
+		boff:         config.GetBackoff(),
+		errLog:       errLog,
+		outLog:       outLog,
+		started:      atomic.NewBool(false),
+		stopped:      atomic.NewBool(false),
+		localStopped: atomic.NewBool(false),
+		running:      atomic.NewBool(false),
+		restartChan:  make(chan bool, 1),
+		done:         make(chan bool),
+		streaming:    atomic.NewBool(false),
 	}, nil
 }
 
+// programOptions are derived from the config when it is loaded
+type programOptions struct {
+	restartPolicy string
+	sysProcAttr   *syscall.SysProcAttr
+	accountEnv    []string
+	umask         int
+	secretStore   *secrets.Store
+}
+
+func getProgramOptions(config *Config) (*programOptions, error) {
+	restartPolicy, err := config.GetRestartPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	sysProcAttr, accountEnv, err := sysProcAttrForOS(config)
+	if err != nil {
+		return nil, err
+	}
+
+	umask, err := config.GetUmask()
+	if err != nil {
+		return nil, err
+	}
+
+	var secretStore *secrets.Store
+	if config.SecretsMode != "" {
+		secretStore, err = config.GetSecretStore()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &programOptions{restartPolicy, sysProcAttr, accountEnv, umask, secretStore}, nil
+}
+
+// getConfig returns the config, Reload replaces it
+func (prg *Program) getConfig() *Config {
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
+	return prg.config
+}
+
+// getOptions returns the options derived from the config
+func (prg *Program) getOptions() *programOptions {
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
+	return prg.options
+}
+
+// getLogs returns the stdout and stderr loggers of the connector
+func (prg *Program) getLogs() (logger.Logger, logger.Logger) {
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
+	return prg.outLog, prg.errLog
+}
+
+// getDevice returns whether the device is stopped and its version,
+// under the changesMutex since the interval fetches the device
+// and Reload replaces it when the Tag changes
+func (prg *Program) getDevice() (bool, string) {
+	prg.changesMutex.Lock()
+	defer prg.changesMutex.Unlock()
+	return prg.connector.Stopped(), prg.connector.Version()
+}
+
+func (prg *Program) resetBackoff() {
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
+	prg.boff.Reset()
+}
+
+func (prg *Program) nextBackoff() time.Duration {
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
+	return prg.boff.Duration()
+}
+
+// isCurrentRun returns false once another run started or the program stopped
+func (prg *Program) isCurrentRun(currentRun string) bool {
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
+	return currentRun == prg.currentRun
+}
+
+// isDone returns true once the program is stopped
+func (prg *Program) isDone() bool {
+	select {
+	case <-prg.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// wait sleeps for the duration, returning false
+// when the program is stopped in the meantime
+func (prg *Program) wait(duration time.Duration) bool {
+	select {
+	case <-prg.done:
+		return false
+	case <-time.After(duration):
+		return true
+	}
+}
+
 // Start service but really
 func (prg *Program) Start(_ service.Service) error {
-	mainLogger.Info("program.Start", fmt.Sprintf("starting %v", prg.config.DisplayName))
-	prg.shouldRestart = true
+	mainLogger.Info("program.Start", fmt.Sprintf("starting %v", prg.getConfig().DisplayName))
 	go prg.restartLoop()
+	if prg.stream != nil {
+		go prg.subscribe()
+	}
 	prg.restartChan <- true
 	return nil
 }
 
 // Stop service but really
 func (prg *Program) Stop(_ service.Service) error {
-	mainLogger.Info("program.Stop", fmt.Sprintf("stopping %v", prg.config.DisplayName))
-	defer prg.errLog.Close()
-	defer prg.outLog.Close()
-	prg.started = false
-	prg.shouldRestart = false
+	mainLogger.Info("program.Stop", fmt.Sprintf("stopping %v", prg.getConfig().DisplayName))
+	prg.shutdown()
+	outLog, errLog := prg.getLogs()
+	defer errLog.Close()
+	defer outLog.Close()
+	if prg.errorReporter != nil {
+		defer prg.errorReporter.Close()
+	}
+	defer prg.telemetry.close()
+	prg.started.Store(false)
+	if prg.stream != nil {
+		prg.stream.Close()
+	}
 	return nil
 }
 
+// Remove stops the connector and the program for good
+func (prg *Program) Remove() error {
+	prg.shutdown()
+	err := prg.stop()
+	if err != nil {
+		mainLogger.Error("program.Remove", "Error stopping connector", err)
+	}
+	return prg.Stop(nil)
+}
+
+// shutdown ends the run and stops the restart loop, the
+// interval and the goroutines waiting on the program
+func (prg *Program) shutdown() {
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
+	if prg.isDone() {
+		return
+	}
+	close(prg.done)
+	prg.currentRun = ""
+	if prg.interval != nil {
+		prg.interval.Clear()
+		prg.interval = nil
+	}
+}
+
+// Status returns the current state of the program
+func (prg *Program) Status() *Status {
+	ignition := prg.telemetry.get()
+	prg.mutex.Lock()
+	cmd, timeStarted, serviceName := prg.cmd, prg.timeStarted, prg.config.ServiceName
+	prg.mutex.Unlock()
+	running := prg.running.Load()
+	_, version := prg.getDevice()
+	status := &Status{
+		Name:         serviceName,
+		State:        ignition.State,
+		RestartCount: ignition.RestartCount,
+		Running:      running,
+		Stopped:      prg.stopped.Load(),
+		LocalStopped: prg.localStopped.Load(),
+		Version:      version,
+		StartedAt:    timeStarted,
+	}
+	if running && cmd != nil && cmd.Process != nil {
+		status.PID = cmd.Process.Pid
+	}
+	return status
+}
+
+// Restart restarts the connector immediately
+func (prg *Program) Restart() {
+	mainLogger.Info("program.Restart", "restart requested")
+	prg.resetBackoff()
+	prg.resetRestarts()
+	prg.restartWithoutBackoff()
+}
+
+// StopChild stops the connector until StartChild is called
+func (prg *Program) StopChild() {
+	mainLogger.Info("program.StopChild", "stop requested")
+	prg.localStopped.Store(true)
+	prg.restartWithoutBackoff()
+}
+
+// StartChild starts the connector after StopChild was called
+func (prg *Program) StartChild() {
+	mainLogger.Info("program.StartChild", "start requested")
+	prg.localStopped.Store(false)
+	prg.resetBackoff()
+	prg.resetRestarts()
+	prg.restartWithoutBackoff()
+}
+
+// TailLogs returns the last lines of the connector stdout and stderr
+func (prg *Program) TailLogs(lines int) string {
+	outLog, errLog := prg.getLogs()
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "==> stdout <==")
+	fmt.Fprintln(&buf, tailLines(outLog.Get(), lines))
+	fmt.Fprintln(&buf, "==> stderr <==")
+	fmt.Fprint(&buf, tailLines(errLog.Get(), lines))
+	return buf.String()
+}
+
 func (prg *Program) restart() {
 	mainLogger.Info("program.restart", "restart called")
-	prg.restartChan <- true
+	select {
+	case prg.restartChan <- true:
+	case <-prg.done:
+	}
+}
+
+// restartWithoutBackoff restarts the loop immediately, used when the
+// connector is stopped or started on purpose. It never blocks the caller,
+// a restart that is already pending picks up the change as well
+func (prg *Program) restartWithoutBackoff() {
+	mainLogger.Info("program.restartWithoutBackoff", "restart called")
+	select {
+	case prg.restartChan <- false:
+	default:
+		mainLogger.Info("program.restartWithoutBackoff", "restart already pending")
+	}
 }
 
 func (prg *Program) restartLoop() error {
-	for range prg.restartChan {
-		if !prg.shouldRestart {
+	for {
+		var useBackoff bool
+		select {
+		case <-prg.done:
 			mainLogger.Info("program.restartLoop", "should not restart")
 			return nil
+		case useBackoff = <-prg.restartChan:
 		}
-		if prg.started {
+		started := prg.started.Load()
+		if started {
 			mainLogger.Info("program.restartLoop", "restart signal received")
 		}
-		backoffDuration := prg.boff.Duration()
-		currentRun := uuid.NewV4().String()
-		prg.currentRun = currentRun
+		currentRun, ok := prg.newRun()
+		if !ok {
+			mainLogger.Info("program.restartLoop", "should not restart")
+			return nil
+		}
 
-		if prg.started {
+		if started && useBackoff {
+			backoffDuration := prg.nextBackoff()
 			mainLogger.Info("program.restartLoop", fmt.Sprintf("waiting for %v due to backoff", backoffDuration))
-			time.Sleep(backoffDuration)
+			prg.telemetry.update(func(ignition *status.Ignition) {
+				ignition.State = status.StateBackingOff
+				ignition.NextBackoff = int64(backoffDuration / time.Millisecond)
+			})
+			if !prg.wait(backoffDuration) {
+				return nil
+			}
 		}
 		err := prg.stop()
 		if err != nil {
 			mainLogger.Error("program.restartLoop", "failed to stop existing child", err)
 			return err
 		}
-		if prg.started {
+		if started {
 			mainLogger.Info("program.restartLoop", "existing child stopped")
 		}
+		prg.closeRetiredLogs()
+		if prg.errorReporter != nil {
+			prg.errorReporter.Reset()
+		}
+		prg.telemetry.setState(status.StateStarting)
+
+		if prg.shouldRollback() {
+			prg.rollback()
+		}
 
 		err = prg.update()
 		if err != nil {
@@ -122,36 +414,89 @@ func (prg *Program) restartLoop() error {
 		} else {
 			mainLogger.Info("program.restartLoop", "updated")
 		}
-		commandPath := prg.getCommandPath()
-		nodeCommand, err := prg.getExecutable("node")
+
+		if prg.localStopped.Load() {
+			mainLogger.Info("program.restartLoop", "connector is stopped locally, not starting")
+			prg.telemetry.setState(status.StateStopped)
+			prg.checkForChangesOnInterval()
+			prg.started.Store(true)
+			continue
+		}
+
+		deviceStopped, version := prg.getDevice()
+		if deviceStopped {
+			mainLogger.Info("program.restartLoop", "connector is stopped, not starting")
+			prg.telemetry.setState(status.StateStopped)
+			prg.setStopped(true)
+			prg.checkForChangesOnInterval()
+			prg.started.Store(true)
+			continue
+		}
+		prg.setStopped(false)
+
+		config, options := prg.getConfig(), prg.getOptions()
+		command, err := prg.getExecutable(config, config.GetCommand())
 		if err != nil {
 			mainLogger.Error("program.restartLoop", "the executable error", err)
 			return err
 		}
-		prg.cmd = exec.Command(nodeCommand, commandPath)
-		prg.cmd.Dir = prg.config.Dir
-		prg.cmd.Env = prg.getEnv()
-		prg.cmd.SysProcAttr = sysProcAttrForOS()
-		prg.cmd.Stderr = prg.errLog.Stream()
-		prg.cmd.Stdout = prg.outLog.Stream()
-		prg.cmdGroup, err = process.Background(prg.cmd)
-
-		go func() {
-			if waitErr := prg.cmdGroup.Wait(); waitErr != nil {
-				mainLogger.Error("prg.cmd.Wait", "connector Runner error'd", waitErr)
-				if currentRun != prg.currentRun {
-					mainLogger.Info("prg.cmd.Wait", "not the currentRun, ignoring")
-					return
+		env, err := config.GetEnv(options.accountEnv...)
+		if err != nil {
+			mainLogger.Error("program.restartLoop", "the env error", err)
+			return err
+		}
+		cmd := exec.Command(command, config.GetCommandArgs()...)
+		cmd.Dir = config.GetWorkingDir()
+		cmd.Env = env
+		cmd.SysProcAttr = options.sysProcAttr
+		cmd.Stdout, cmd.Stderr = prg.getOutputStreams()
+		afterStart, err := prg.passSecrets(cmd, config, options)
+		if err != nil {
+			mainLogger.Error("program.restartLoop", "the secrets error", err)
+			return err
+		}
+		timeStarted := time.Now()
+		cmdGroup, cgroupPath, err := prg.startRun(currentRun, cmd, timeStarted)
+		afterStart()
+		if err == errStopped {
+			mainLogger.Info("program.restartLoop", "should not restart")
+			return nil
+		}
+		if err == nil {
+			pid := cmd.Process.Pid
+			prg.watchResources(currentRun, pid)
+			mainLogger.InfoWithFields("program.restartLoop", "connector started", logger.Fields{
+				"pid":     pid,
+				"command": append([]string{command}, config.GetCommandArgs()...),
+				"version": version,
+			})
+			prg.telemetry.update(func(ignition *status.Ignition) {
+				ignition.State = status.StateRunning
+				ignition.NextBackoff = 0
+				ignition.FailedReason = ""
+				ignition.PID = pid
+				ignition.ConnectorVersion = version
+				ignition.StartedAt = toMillis(timeStarted)
+				if started {
+					ignition.RestartCount++
 				}
-				prg.restart()
-			}
-		}()
+			})
+		}
+
+		go prg.waitForExit(currentRun, cmd, cmdGroup, cgroupPath)
+
+		if err == nil {
+			prg.watchHealth(currentRun)
+		}
 
 		go func() {
-			time.Sleep(time.Second * 30)
-			if currentRun == prg.currentRun {
-				mainLogger.Info("program.restartLoop", "ran for 30s without dying, resetting backoff")
-				prg.boff.Reset()
+			stabilityWindow := config.StabilityWindow.OrDefault(30 * time.Second)
+			if !prg.wait(stabilityWindow) {
+				return
+			}
+			if prg.isCurrentRun(currentRun) {
+				mainLogger.Info("program.restartLoop", fmt.Sprintf("ran for %v without dying, resetting backoff", stabilityWindow))
+				prg.resetBackoff()
 			}
 		}()
 
@@ -160,66 +505,188 @@ func (prg *Program) restartLoop() error {
 		if err != nil {
 			return err
 		}
-		if prg.started {
+		if started {
 			mainLogger.Info("program.restartLoop", "restarted")
 		}
-		prg.started = true
+		prg.started.Store(true)
 	}
+}
 
-	return prg.stop()
+// errStopped is returned when the program stopped before the run started
+var errStopped = errors.New("program stopped")
+
+// newRun makes a new run current, unless the program stopped
+func (prg *Program) newRun() (string, bool) {
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
+	if prg.isDone() {
+		return "", false
+	}
+	prg.currentRun = uuid.NewV4().String()
+	return prg.currentRun, true
 }
 
-func (prg *Program) updateErrors() error {
-	err := prg.status.UpdateErrors(prg.errLog.Get())
+// startRun starts the connector of the run, the mutex makes
+// sure a stopping program either sees the child or stops it first.
+// Also returns the cgroup of the connector, "" without cgroup limits
+func (prg *Program) startRun(currentRun string, cmd *exec.Cmd, timeStarted time.Time) (*process.Group, string, error) {
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
+	if currentRun != prg.currentRun {
+		return nil, "", errStopped
+	}
+	cgroupPath, err := prepareCgroup(prg.config)
 	if err != nil {
-		mainLogger.Error("program.updateErrors", "Error updating errors", err)
-	} else {
-		mainLogger.Info("program.updateErrors", "Updated status device with errors")
+		mainLogger.Error("program.startRun", "Error applying cgroup limits", err)
+		cgroupPath = ""
 	}
-	return nil
+	cmdGroup, err := prg.background(cmd, prg.config, prg.options, cgroupPath)
+	prg.cmd, prg.cmdGroup = cmd, cmdGroup
+	if err != nil {
+		removeCgroup(cgroupPath)
+		return nil, "", err
+	}
+	prg.running.Store(true)
+	prg.timeStarted = timeStarted
+	return cmdGroup, cgroupPath, nil
+}
+
+// getOutputStreams returns the stdout and stderr streams of the
+// connector, stderr also goes to the status device when there is one
+func (prg *Program) getOutputStreams() (io.Writer, io.Writer) {
+	outLog, errLog := prg.getLogs()
+	if prg.errorReporter == nil {
+		return outLog.Stream(), errLog.Stream()
+	}
+	return outLog.Stream(), io.MultiWriter(errLog.Stream(), prg.errorReporter)
+}
+
+func (prg *Program) setLastUpdate(tag string, updateErr error) {
+	lastUpdate := &status.UpdateResult{
+		Tag:    tag,
+		Result: "updated",
+		At:     status.Now(),
+	}
+	if updateErr != nil {
+		lastUpdate.Result = "failed"
+		lastUpdate.Error = updateErr.Error()
+	}
+	prg.telemetry.update(func(ignition *status.Ignition) {
+		ignition.LastUpdate = lastUpdate
+	})
+}
+
+func (prg *Program) updateFailed(tag string, updateErr error) {
+	err := prg.status.UpdateFailed(tag, updateErr)
+	if err != nil {
+		mainLogger.Error("program.updateFailed", "Error updating status device", err)
+		return
+	}
+	mainLogger.Info("program.updateFailed", "Updated status device with update error")
+}
+
+func (prg *Program) setStopped(stopped bool) {
+	if prg.started.Load() && prg.stopped.Load() == stopped {
+		return
+	}
+	prg.stopped.Store(stopped)
+	err := prg.status.UpdateStopped(stopped)
+	if err != nil {
+		mainLogger.Error("program.setStopped", "Error updating status device", err)
+		return
+	}
+	mainLogger.Info("program.setStopped", fmt.Sprintf("Updated status device with stopped %v", stopped))
 }
 
 func (prg *Program) stop() error {
-	if prg.started {
+	if prg.started.Load() {
 		mainLogger.Info("program.stop", "stopping connector")
 	}
-	if prg.cmdGroup == nil {
+	prg.mutex.Lock()
+	cmdGroup := prg.cmdGroup
+	prg.mutex.Unlock()
+	if cmdGroup == nil {
 		return nil
 	}
-	err := prg.cmdGroup.Terminate(time.Second * 30)
+	prg.running.Store(false)
+	err := cmdGroup.Terminate(time.Second * 30)
 	if _, isExitError := err.(*exec.ExitError); isExitError {
 		return nil
 	}
 	return err
 }
 
-func (prg *Program) getCommandPath() string {
-	return fmt.Sprintf(".%s%s", string(filepath.Separator), filepath.Join("node_modules", "meshblu-connector-runner", "command.js"))
-}
-
+// checkForChanges restarts the connector when the device changed,
+// the restart is signalled after the changesMutex is released
+// so a restart waiting for the loop never blocks another check
 func (prg *Program) checkForChanges() error {
+	prg.changesMutex.Lock()
 	err := prg.connector.Fetch()
 	if err != nil {
+		prg.changesMutex.Unlock()
 		mainLogger.Error("program.checkForChanges", "Device Update Error", err)
 		return err
 	}
 	versionChange := prg.connector.DidVersionChange()
+	stopChange := prg.connector.DidStopChange()
+	stopped := prg.connector.Stopped()
+	version := prg.connector.Version()
+	clearRolledBack := versionChange && prg.rolledBackTag != ""
+	if clearRolledBack {
+		prg.rolledBackTag = ""
+	}
+	prg.changesMutex.Unlock()
+
+	if clearRolledBack {
+		err = prg.uc.ClearRolledBack()
+		if err != nil {
+			mainLogger.Error("program.checkForChanges", "Failed to run uc.ClearRolledBack", err)
+		}
+	}
 	if versionChange {
-		mainLogger.Info("program.checkForChanges", fmt.Sprintf("Device Version Change %v", prg.connector.Version()))
-		prg.boff.Reset()
+		mainLogger.Info("program.checkForChanges", fmt.Sprintf("Device Version Change %v", version))
+		prg.resetBackoff()
+		prg.resetRestarts()
 		prg.restart()
+		return nil
+	}
+	if stopChange {
+		if stopped {
+			mainLogger.Info("program.checkForChanges", "Device Stopped, stopping connector")
+		} else {
+			mainLogger.Info("program.checkForChanges", "Device Started, starting connector")
+		}
+		prg.resetBackoff()
+		prg.resetRestarts()
+		prg.restartWithoutBackoff()
 	}
 	return nil
 }
 
-func (prg *Program) update() error {
+// fetchTag fetches the device under the changesMutex, returning
+// the version it should run and whether it was rolled back
+func (prg *Program) fetchTag() (string, bool, error) {
+	prg.changesMutex.Lock()
+	defer prg.changesMutex.Unlock()
 	err := prg.connector.Fetch()
+	if err != nil {
+		return "", false, err
+	}
+	tag := prg.connector.VersionWithV()
+	return tag, tag == prg.rolledBackTag, nil
+}
+
+func (prg *Program) update() error {
+	tag, rolledBack, err := prg.fetchTag()
 	if err != nil {
 		mainLogger.Error("program.update", "Failed to run prg.connector.Fetch", err)
 		return err
 	}
 
-	tag := prg.connector.VersionWithV()
+	if rolledBack {
+		mainLogger.Info("program.update", fmt.Sprintf("skipping update to %s, it was rolled back", tag))
+		return nil
+	}
 	needsUpdate, err := prg.uc.NeedsUpdate(tag)
 	if err != nil {
 		mainLogger.Error("program.update", "Failed to run prg.uc.needsUpdate", err)
@@ -229,40 +696,302 @@ func (prg *Program) update() error {
 		mainLogger.Info("program.update", fmt.Sprintf("no update needed (%s)", tag))
 		return nil
 	}
+	prg.telemetry.setState(status.StateUpdating)
 	err = prg.uc.Do(tag)
+	prg.setLastUpdate(tag, err)
 	if err != nil {
-		mainLogger.Error("program.update", "Failed to run uc.Do", err)
+		if updateconnector.IsVerificationError(err) {
+			mainLogger.Error("program.update", fmt.Sprintf("Refusing update to %s", tag), err)
+		} else {
+			mainLogger.Error("program.update", "Failed to run uc.Do", err)
+		}
+		prg.updateFailed(tag, err)
 		return err
 	}
+	prg.updateMutex.Lock()
+	defer prg.updateMutex.Unlock()
+	prg.updatedAt = time.Now()
+	prg.updatedTag = tag
+	prg.updateCrashes = 0
 	return nil
 }
 
+// waitForExit handles the exit of the connector of the run
+// and removes its cgroup
+func (prg *Program) waitForExit(currentRun string, cmd *exec.Cmd, cmdGroup *process.Group, cgroupPath string) {
+	if cmdGroup == nil {
+		return
+	}
+	waitErr := cmdGroup.Wait()
+	removeCgroup(cgroupPath)
+	if !prg.isCurrentRun(currentRun) {
+		mainLogger.Info("prg.cmd.Wait", "not the currentRun, ignoring")
+		return
+	}
+	prg.running.Store(false)
+	exit := getExit(cmd, waitErr)
+	if prg.isUnhealthyRun(currentRun) {
+		exit.Unhealthy = true
+		exit.Reason = fmt.Sprintf("%s after it was unhealthy", exit.Reason)
+	}
+	prg.handleExit(exit)
+}
+
+// handleExit records why the connector exited and
+// restarts it according to the restart policy
+func (prg *Program) handleExit(exit *status.Exit) {
+	fields := logger.Fields{
+		"code":   exit.Code,
+		"signal": exit.Signal,
+		"reason": exit.Reason,
+	}
+	state := status.StateExited
+	if exit.Failed() {
+		state = status.StateCrashed
+		mainLogger.ErrorWithFields("prg.cmd.Wait", "connector crashed", errors.New(exit.Reason), fields)
+	} else {
+		mainLogger.InfoWithFields("prg.cmd.Wait", "connector exited", fields)
+	}
+	prg.telemetry.update(func(ignition *status.Ignition) {
+		ignition.State = state
+		ignition.PID = 0
+		ignition.LastExit = exit
+	})
+	if exit.Failed() {
+		prg.recordCrash()
+	}
+	if !prg.shouldRestartAfter(exit) {
+		return
+	}
+	prg.restart()
+}
+
+// shouldRestartAfter applies the restart policy and the
+// MaxRestarts circuit breaker to an exited connector
+func (prg *Program) shouldRestartAfter(exit *status.Exit) bool {
+	restartPolicy := prg.getOptions().restartPolicy
+	if restartPolicy == RestartNever || (restartPolicy == RestartOnFailure && !exit.Failed()) {
+		mainLogger.Info("program.shouldRestartAfter", fmt.Sprintf("restart policy is %s, not restarting after it %s", restartPolicy, exit.Reason))
+		return false
+	}
+	if !prg.recordRestart() {
+		prg.fail(fmt.Sprintf("restarted more than %v times within %v", prg.getConfig().MaxRestarts, prg.getRestartWindow()))
+		return false
+	}
+	return true
+}
+
+// recordRestart counts the restarts within the RestartWindow,
+// returning false once there were more than MaxRestarts
+func (prg *Program) recordRestart() bool {
+	maxRestarts := prg.getConfig().MaxRestarts
+	if maxRestarts <= 0 {
+		return true
+	}
+	restartWindow := prg.getRestartWindow()
+	prg.restartMutex.Lock()
+	defer prg.restartMutex.Unlock()
+	now := time.Now()
+	restarts := []time.Time{}
+	for _, restartedAt := range prg.restarts {
+		if now.Sub(restartedAt) < restartWindow {
+			restarts = append(restarts, restartedAt)
+		}
+	}
+	prg.restarts = append(restarts, now)
+	return len(prg.restarts) <= maxRestarts
+}
+
+func (prg *Program) resetRestarts() {
+	prg.restartMutex.Lock()
+	defer prg.restartMutex.Unlock()
+	prg.restarts = nil
+}
+
+func (prg *Program) getRestartWindow() time.Duration {
+	return prg.getConfig().RestartWindow.OrDefault(10 * time.Minute)
+}
+
+// fail parks the connector until it is restarted,
+// started or changed on the device
+func (prg *Program) fail(reason string) {
+	mainLogger.Error("program.fail", "connector failed, not restarting", errors.New(reason))
+	prg.telemetry.update(func(ignition *status.Ignition) {
+		ignition.State = status.StateFailed
+		ignition.PID = 0
+		ignition.NextBackoff = 0
+		ignition.FailedReason = reason
+	})
+}
+
+// recordCrash counts the crashes shortly after an update
+func (prg *Program) recordCrash() {
+	config := prg.getConfig()
+	prg.updateMutex.Lock()
+	defer prg.updateMutex.Unlock()
+	if config.DisableRollback || prg.updatedTag == "" {
+		return
+	}
+	window := config.RollbackWindow.OrDefault(5 * time.Minute)
+	if time.Since(prg.updatedAt) > window {
+		prg.updatedTag = ""
+		return
+	}
+	prg.updateCrashes++
+	mainLogger.Info("program.recordCrash", fmt.Sprintf("%s crashed %v times since the update", prg.updatedTag, prg.updateCrashes))
+}
+
+func (prg *Program) shouldRollback() bool {
+	rollbackCrashes := prg.getConfig().GetRollbackCrashes()
+	prg.updateMutex.Lock()
+	defer prg.updateMutex.Unlock()
+	if prg.updatedTag == "" {
+		return false
+	}
+	return prg.updateCrashes >= rollbackCrashes
+}
+
+func (prg *Program) rollback() {
+	prg.updateMutex.Lock()
+	tag, crashes := prg.updatedTag, prg.updateCrashes
+	prg.updatedTag = ""
+	prg.updateCrashes = 0
+	prg.updateMutex.Unlock()
+	if tag == "" {
+		return
+	}
+	mainLogger.Info("program.rollback", fmt.Sprintf("%s is crash looping, rolling back", tag))
+	err := prg.uc.Rollback()
+	if err != nil {
+		mainLogger.Error("program.rollback", "Failed to run uc.Rollback", err)
+		return
+	}
+	prg.changesMutex.Lock()
+	prg.rolledBackTag = tag
+	prg.changesMutex.Unlock()
+	prg.resetBackoff()
+	prg.telemetry.update(func(ignition *status.Ignition) {
+		ignition.LastUpdate = &status.UpdateResult{
+			Tag:    tag,
+			Result: "rolled-back",
+			Error:  fmt.Sprintf("crashed %v times after the update", crashes),
+			At:     status.Now(),
+		}
+	})
+	prg.updateFailed(tag, fmt.Errorf("rolled back after %v crashes", crashes))
+}
+
 func (prg *Program) checkForChangesOnInterval() {
 	mainLogger.Info("program.checkForChangesOnInterval", "started")
 
+	prg.mutex.Lock()
+	defer prg.mutex.Unlock()
 	if prg.interval != nil {
 		prg.interval.Clear()
+		prg.interval = nil
+	}
+	if prg.isDone() {
+		return
 	}
 
 	duration := time.Minute
 	prg.interval = interval.SetInterval(duration, func() {
+		if prg.streaming.Load() {
+			return
+		}
 		prg.checkForChanges()
 	})
 }
 
+// subscribe checks for changes on every device stream event,
+// reconnecting with a backoff while the interval polls instead
+func (prg *Program) subscribe() {
+	boff := &backoff.Backoff{
+		Min: time.Second,
+		Max: time.Minute,
+	}
+	for !prg.isDone() {
+		err := prg.stream.Listen(func() {
+			mainLogger.Info("program.subscribe", "connected to the device stream")
+			prg.streaming.Store(true)
+			boff.Reset()
+			prg.checkForChanges()
+		}, func() {
+			prg.checkForChanges()
+		})
+		prg.streaming.Store(false)
+		if prg.isDone() {
+			return
+		}
+		if err != nil {
+			mainLogger.Error("program.subscribe", "device stream unavailable, polling for changes", err)
+		}
+		if !prg.wait(boff.Duration()) {
+			return
+		}
+	}
+}
+
 func (prg *Program) getFullConnectorName() string {
-	return fmt.Sprintf("meshblu-%s", prg.config.ConnectorName)
+	return fmt.Sprintf("meshblu-%s", prg.getConfig().ConnectorName)
+}
+
+// passSecrets hands the secret store to the command,
+// the returned func must be called once it has started
+func (prg *Program) passSecrets(cmd *exec.Cmd, config *Config, options *programOptions) (func(), error) {
+	if options.secretStore == nil {
+		return func() {}, nil
+	}
+	values, err := options.secretStore.Read()
+	if err != nil {
+		return nil, err
+	}
+	if config.SecretsMode == secrets.ModeFD {
+		return secrets.PassFD(cmd, values)
+	}
+	uid, gid := getCredentialIDs(options.sysProcAttr)
+	return secrets.ServeSocket(cmd, values, uid, gid)
 }
 
-func (prg *Program) getEnv() []string {
-	pathEnv := GetPathEnv(prg.config.BinPath)
-	return GetEnviron(pathEnv)
+// background starts the command in its process group, through
+// the ChildCommand when the umask, rlimits or cgroup have to be set.
+// The child waits until it was moved into the cgroup
+func (prg *Program) background(cmd *exec.Cmd, config *Config, options *programOptions, cgroupPath string) (*process.Group, error) {
+	spec := &childSpec{Umask: options.umask, Rlimits: config.Limits.getRlimits()}
+	var joined *os.File
+	if cgroupPath != "" {
+		reader, writer, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		defer writer.Close()
+		cmd.ExtraFiles = append(cmd.ExtraFiles, reader)
+		spec.CgroupFD = 2 + len(cmd.ExtraFiles)
+		joined = writer
+	}
+	if !spec.isEmpty() {
+		err := wrapChild(cmd, spec)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cmdGroup, err := process.Background(cmd)
+	if err != nil || joined == nil {
+		return cmdGroup, err
+	}
+	err = joinCgroup(cgroupPath, cmd.Process.Pid)
+	if err != nil {
+		mainLogger.Error("program.background", "Error moving the connector into its cgroup", err)
+		return cmdGroup, nil
+	}
+	joined.Write([]byte{1})
+	return cmdGroup, nil
 }
 
 // getExecutable should return the correct executable
-func (prg *Program) getExecutable(name string) (string, error) {
-	thePath := filepath.Join(prg.config.BinPath, name)
-	file, err := exec.LookPath(thePath)
+func (prg *Program) getExecutable(config *Config, name string) (string, error) {
+	file, err := config.lookExecutable(name)
 	if err != nil {
 		mainLogger.Error("program.getExecutable", "Error getting executable", err)
 		return "", err
@@ -270,3 +999,15 @@ func (prg *Program) getExecutable(name string) (string, error) {
 	mainLogger.Info("program.getExectuable", fmt.Sprintf("using executable %s", file))
 	return file, nil
 }
+
+func tailLines(data []byte, lines int) string {
+	trimmed := strings.TrimRight(string(data), "\n")
+	if trimmed == "" {
+		return ""
+	}
+	all := strings.Split(trimmed, "\n")
+	if lines > 0 && len(all) > lines {
+		all = all[len(all)-lines:]
+	}
+	return strings.Join(all, "\n")
+}
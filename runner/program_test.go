@@ -0,0 +1,232 @@
+package runner_test
+
+import (
+	"os"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Program", func() {
+	var dir string
+	var config *runner.Config
+	var device *fakeConnector
+	var statusDevice *fakeStatus
+	var uc *fakeUpdateConnector
+
+	newProgram := func() *runner.Program {
+		prg, err := runner.NewTestProgram(config, device, statusDevice, uc)
+		Expect(err).NotTo(HaveOccurred())
+		return prg
+	}
+
+	BeforeEach(func() {
+		config = newTestConfig("program")
+		dir = config.Dir
+		device = newFakeConnector("1.0.0")
+		statusDevice = &fakeStatus{}
+		uc = &fakeUpdateConnector{tag: "v1.0.0"}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("the crash rollback", func() {
+		var prg *runner.Program
+
+		BeforeEach(func() {
+			prg = newProgram()
+			device.set("2.0.0", false)
+			Expect(prg.Update()).To(Succeed())
+			Expect(uc.updates).To(Equal([]string{"v2.0.0"}))
+		})
+
+		It("should not roll back a stable update", func() {
+			Expect(prg.ShouldRollback()).To(BeFalse())
+		})
+
+		It("should roll back after the RollbackCrashes", func() {
+			prg.RecordCrash()
+			prg.RecordCrash()
+			Expect(prg.ShouldRollback()).To(BeFalse())
+			prg.RecordCrash()
+			Expect(prg.ShouldRollback()).To(BeTrue())
+		})
+
+		Describe("with RollbackCrashes", func() {
+			It("should roll back after that many crashes", func() {
+				config.RollbackCrashes = 1
+				prg.RecordCrash()
+				Expect(prg.ShouldRollback()).To(BeTrue())
+			})
+		})
+
+		Describe("when the crashes are after the RollbackWindow", func() {
+			It("should keep the update", func() {
+				config.RollbackWindow = runner.Duration(time.Minute)
+				prg.SetUpdatedAt(time.Now().Add(-2 * time.Minute))
+				prg.RecordCrash()
+				prg.RecordCrash()
+				prg.RecordCrash()
+				Expect(prg.ShouldRollback()).To(BeFalse())
+			})
+		})
+
+		Describe("with DisableRollback", func() {
+			It("should keep the update", func() {
+				config.DisableRollback = true
+				prg.RecordCrash()
+				prg.RecordCrash()
+				prg.RecordCrash()
+				Expect(prg.ShouldRollback()).To(BeFalse())
+			})
+		})
+
+		Describe("after another update", func() {
+			It("should count the crashes again", func() {
+				prg.RecordCrash()
+				prg.RecordCrash()
+				device.set("3.0.0", false)
+				Expect(prg.Update()).To(Succeed())
+				prg.RecordCrash()
+				Expect(prg.ShouldRollback()).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("without an update", func() {
+		It("should never roll back", func() {
+			prg := newProgram()
+			prg.RecordCrash()
+			prg.RecordCrash()
+			prg.RecordCrash()
+			Expect(prg.ShouldRollback()).To(BeFalse())
+		})
+	})
+
+	Describe("when the device version was rolled back before", func() {
+		var prg *runner.Program
+
+		BeforeEach(func() {
+			uc.rolledBack = "v2.0.0"
+			device.set("2.0.0", false)
+			prg = newProgram()
+		})
+
+		It("should not update to it again", func() {
+			Expect(prg.Update()).To(Succeed())
+			Expect(uc.updates).To(BeEmpty())
+		})
+
+		Describe("when the device version changes", func() {
+			It("should forget it", func() {
+				Expect(device.Fetch()).To(Succeed())
+				device.set("3.0.0", false)
+				Expect(prg.CheckForChanges()).To(Succeed())
+				Expect(uc.RolledBackTag()).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("->CheckForChanges", func() {
+		var prg *runner.Program
+
+		BeforeEach(func() {
+			prg = newProgram()
+		})
+
+		Describe("when the version changed", func() {
+			It("should restart with the backoff", func() {
+				device.set("2.0.0", false)
+				Expect(prg.CheckForChanges()).To(Succeed())
+				Expect(prg.RestartChan()).To(Receive(BeTrue()))
+			})
+		})
+
+		Describe("when the device was stopped", func() {
+			It("should restart without the backoff", func() {
+				device.set("1.0.0", true)
+				Expect(prg.CheckForChanges()).To(Succeed())
+				Expect(prg.RestartChan()).To(Receive(BeFalse()))
+			})
+		})
+
+		Describe("when nothing changed", func() {
+			It("should not restart", func() {
+				Expect(prg.CheckForChanges()).To(Succeed())
+				Expect(prg.RestartChan()).NotTo(Receive())
+			})
+		})
+
+		Describe("when the restart is waiting for the loop", func() {
+			It("should not block the update", func() {
+				device.set("2.0.0", false)
+				Expect(prg.CheckForChanges()).To(Succeed())
+				device.set("3.0.0", false)
+				checked := make(chan error, 1)
+				go func() {
+					checked <- prg.CheckForChanges()
+				}()
+				Eventually(device.Version).Should(Equal("3.0.0"))
+
+				updated := make(chan error, 1)
+				go func() {
+					updated <- prg.Update()
+				}()
+				Eventually(updated).Should(Receive(BeNil()))
+				Expect(uc.updates).To(Equal([]string{"v3.0.0"}))
+
+				Expect(prg.RestartChan()).To(Receive(BeTrue()))
+				Eventually(checked).Should(Receive(BeNil()))
+			})
+		})
+	})
+
+	Describe("the control commands", func() {
+		var prg *runner.Program
+
+		BeforeEach(func() {
+			prg = newProgram()
+			prg.Restart()
+		})
+
+		Describe("when a restart is already pending", func() {
+			It("should not block", func() {
+				done := make(chan bool, 1)
+				go func() {
+					prg.Restart()
+					prg.StopChild()
+					prg.StartChild()
+					done <- true
+				}()
+				Eventually(done).Should(Receive())
+				Expect(prg.RestartChan()).To(Receive(BeFalse()))
+				Expect(prg.RestartChan()).NotTo(Receive())
+			})
+
+			It("should keep the change for the pending restart", func() {
+				prg.StopChild()
+				Expect(prg.Status().LocalStopped).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("->Remove", func() {
+		It("should not block the restarts after it", func() {
+			prg := newProgram()
+			prg.Restart()
+			Expect(prg.Remove()).To(Succeed())
+			done := make(chan bool, 1)
+			go func() {
+				prg.HandleExit(&status.Exit{Code: 1, Reason: "exited with code 1"})
+				done <- true
+			}()
+			Eventually(done).Should(Receive())
+		})
+	})
+})
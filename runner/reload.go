@@ -0,0 +1,308 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/connector"
+	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+)
+
+// ConfigChange defines a field that changed between two configs
+type ConfigChange struct {
+	Field    string
+	Old, New string
+}
+
+// applyLiveFields copies the fields that apply without a restart,
+// the ignition update fields are read by forever
+func applyLiveFields(config, newConfig *Config) {
+	config.RollbackWindow = newConfig.RollbackWindow
+	config.RollbackCrashes = newConfig.RollbackCrashes
+	config.DisableRollback = newConfig.DisableRollback
+	config.DisableSelfUpdate = newConfig.DisableSelfUpdate
+	config.IgnitionDownloadURL = newConfig.IgnitionDownloadURL
+	config.IgnitionVersionURL = newConfig.IgnitionVersionURL
+	config.UpdateChannel = newConfig.UpdateChannel
+	config.PinnedVersion = newConfig.PinnedVersion
+	config.IgnitionMinVersion = newConfig.IgnitionMinVersion
+	config.IgnitionMaxVersion = newConfig.IgnitionMaxVersion
+	config.RestartPolicy = newConfig.RestartPolicy
+	config.BackoffMin = newConfig.BackoffMin
+	config.BackoffMax = newConfig.BackoffMax
+	config.BackoffFactor = newConfig.BackoffFactor
+	config.BackoffJitter = newConfig.BackoffJitter
+	config.StabilityWindow = newConfig.StabilityWindow
+	config.MaxRestarts = newConfig.MaxRestarts
+	config.RestartWindow = newConfig.RestartWindow
+	config.Connectors = newConfig.Connectors
+	config.ConnectorsDir = newConfig.ConnectorsDir
+}
+
+// applyChildFields copies the fields that apply by restarting
+// the connector, returning true when one of them changed
+func applyChildFields(config, newConfig *Config) bool {
+	oldConfig := *config
+	config.Tag = newConfig.Tag
+	config.BinPath = newConfig.BinPath
+	config.Stderr = newConfig.Stderr
+	config.Stdout = newConfig.Stdout
+	config.Command = newConfig.Command
+	config.Args = newConfig.Args
+	config.Entrypoint = newConfig.Entrypoint
+	config.LogMaxSize = newConfig.LogMaxSize
+	config.LogRotateEvery = newConfig.LogRotateEvery
+	config.LogMaxBackups = newConfig.LogMaxBackups
+	config.LogMaxAge = newConfig.LogMaxAge
+	config.LogCompress = newConfig.LogCompress
+	config.LogMemoryBytes = newConfig.LogMemoryBytes
+	config.LogMemoryLines = newConfig.LogMemoryLines
+	config.HealthChecks = newConfig.HealthChecks
+	config.Limits = newConfig.Limits
+	config.User = newConfig.User
+	config.Group = newConfig.Group
+	config.Groups = newConfig.Groups
+	config.Umask = newConfig.Umask
+	config.WorkingDir = newConfig.WorkingDir
+	config.Env = newConfig.Env
+	config.EnvFiles = newConfig.EnvFiles
+	config.InheritEnv = newConfig.InheritEnv
+	config.DenyEnv = newConfig.DenyEnv
+	return !reflect.DeepEqual(&oldConfig, config)
+}
+
+// hiddenFields may hold secrets, only the change is logged
+var hiddenFields = map[string]bool{"Env": true}
+
+// DiffConfigs returns the fields that changed, sorted by name
+func DiffConfigs(oldConfig, newConfig *Config) []*ConfigChange {
+	changes := []*ConfigChange{}
+	oldValue := reflect.ValueOf(oldConfig).Elem()
+	newValue := reflect.ValueOf(newConfig).Elem()
+	configType := oldValue.Type()
+	for i := 0; i < configType.NumField(); i++ {
+		field := configType.Field(i).Name
+		oldField := oldValue.Field(i).Interface()
+		newField := newValue.Field(i).Interface()
+		if reflect.DeepEqual(oldField, newField) {
+			continue
+		}
+		change := &ConfigChange{Field: field, Old: "[hidden]", New: "[hidden]"}
+		if !hiddenFields[field] {
+			change.Old, change.New = formatConfigValue(oldField), formatConfigValue(newField)
+		}
+		changes = append(changes, change)
+	}
+	sort.Sort(byField(changes))
+	return changes
+}
+
+// byField sorts the changes by the field name
+type byField []*ConfigChange
+
+func (changes byField) Len() int           { return len(changes) }
+func (changes byField) Swap(i, j int)      { changes[i], changes[j] = changes[j], changes[i] }
+func (changes byField) Less(i, j int) bool { return changes[i].Field < changes[j].Field }
+
+func formatConfigValue(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(data)
+}
+
+// Reload applies a changed config to the program, restarting
+// the connector when a changed field requires it. The other
+// fields only apply once ignition restarts, they keep their
+// current value in the config
+func (prg *Program) Reload(config *Config) error {
+	oldConfig := prg.getConfig()
+	changes := DiffConfigs(oldConfig, config)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	applied := *oldConfig
+	applyLiveFields(&applied, config)
+	restartChild := applyChildFields(&applied, config)
+	options, err := getProgramOptions(&applied)
+	if err != nil {
+		return err
+	}
+	pending := map[string]bool{}
+	for _, change := range DiffConfigs(&applied, config) {
+		pending[change.Field] = true
+	}
+	for _, change := range changes {
+		fields := logger.Fields{"field": change.Field, "old": change.Old, "new": change.New}
+		if pending[change.Field] {
+			mainLogger.InfoWithFields("program.Reload", "config changed, applies when ignition restarts", fields)
+			continue
+		}
+		mainLogger.InfoWithFields("program.Reload", "config changed", fields)
+	}
+	*config = applied
+	newLogs := config.Stdout != oldConfig.Stdout || config.Stderr != oldConfig.Stderr ||
+		!reflect.DeepEqual(config.GetLogOptions(), oldConfig.GetLogOptions())
+
+	var outLog, errLog logger.Logger
+	if newLogs {
+		outLog, err = logger.NewLogger(config.Stdout, false, config.GetLogOptions())
+		if err != nil {
+			return err
+		}
+		errLog, err = logger.NewLogger(config.Stderr, true, config.GetLogOptions())
+		if err != nil {
+			outLog.Close()
+			return err
+		}
+	}
+
+	var connectorClient connector.Connector
+	if config.Tag != oldConfig.Tag && prg.meshbluClient != nil {
+		connectorClient, err = connector.New(prg.meshbluClient, prg.deviceUUID, config.Tag)
+		if err == nil {
+			err = connectorClient.Fetch()
+		}
+		if err != nil {
+			if newLogs {
+				outLog.Close()
+				errLog.Close()
+			}
+			return err
+		}
+	}
+
+	if connectorClient != nil {
+		prg.changesMutex.Lock()
+		prg.connector = connectorClient
+		prg.changesMutex.Unlock()
+	}
+	prg.mutex.Lock()
+	if newLogs {
+		prg.retiredLogs = append(prg.retiredLogs, prg.outLog, prg.errLog)
+		prg.outLog, prg.errLog = outLog, errLog
+	}
+	if !reflect.DeepEqual(oldConfig.GetBackoff(), config.GetBackoff()) {
+		prg.boff = config.GetBackoff()
+	}
+	prg.options = options
+	prg.config = config
+	prg.mutex.Unlock()
+
+	if restartChild && prg.started.Load() {
+		mainLogger.Info("program.Reload", "restarting connector to apply the config")
+		prg.restartWithoutBackoff()
+	}
+	return nil
+}
+
+// closeRetiredLogs closes the loggers replaced by Reload
+// once the connector writing to them has stopped
+func (prg *Program) closeRetiredLogs() {
+	prg.mutex.Lock()
+	retiredLogs := prg.retiredLogs
+	prg.retiredLogs = nil
+	prg.mutex.Unlock()
+	for _, retiredLog := range retiredLogs {
+		retiredLog.Close()
+	}
+}
+
+// Reload applies the changed config to the running connectors,
+// starting the added and stopping the removed ones in the
+// multi connector mode
+func (client *Client) Reload(config *Config) error {
+	err := config.Validate()
+	if err != nil {
+		return err
+	}
+	configs, err := config.GetConnectorConfigs()
+	if err != nil {
+		return err
+	}
+
+	if !config.IsMulti() {
+		for _, prg := range client.getPrograms("") {
+			err = prg.Reload(configs[0])
+			if err != nil {
+				return err
+			}
+		}
+		client.setConfig(config)
+		return nil
+	}
+
+	for _, change := range DiffConfigs(client.getConfig(), config) {
+		fields := logger.Fields{"field": change.Field, "old": change.Old, "new": change.New}
+		mainLogger.InfoWithFields("runner.Reload", "config changed", fields)
+	}
+	newConfigs := map[string]*Config{}
+	for _, connectorConfig := range configs {
+		newConfigs[connectorConfig.ServiceName] = connectorConfig
+	}
+	running := map[string]bool{}
+	setups := client.getSetups()
+	for serviceName := range setups {
+		if _, ok := newConfigs[serviceName]; !ok {
+			mainLogger.Info("runner.Reload", fmt.Sprintf("%s was removed, canceling its setup", serviceName))
+			client.cancelSetup(serviceName)
+		}
+	}
+	for _, prg := range client.getPrograms("") {
+		serviceName := prg.getConfig().ServiceName
+		running[serviceName] = true
+		connectorConfig, ok := newConfigs[serviceName]
+		if !ok {
+			mainLogger.Info("runner.Reload", fmt.Sprintf("%s was removed, stopping it", serviceName))
+			client.removeProgram(prg)
+			continue
+		}
+		err = prg.Reload(connectorConfig)
+		if err != nil {
+			mainLogger.Error("runner.Reload", fmt.Sprintf("Error reloading %s", serviceName), err)
+		}
+	}
+	for serviceName, connectorConfig := range newConfigs {
+		if running[serviceName] {
+			continue
+		}
+		if setups[serviceName] {
+			mainLogger.Info("runner.Reload", fmt.Sprintf("%s is still being set up, retrying with the new config", serviceName))
+		} else {
+			mainLogger.Info("runner.Reload", fmt.Sprintf("%s was added, starting it", serviceName))
+		}
+		client.setupProgram(connectorConfig)
+	}
+	client.setConfig(config)
+	return nil
+}
+
+func (client *Client) getConfig() *Config {
+	client.prgsMutex.Lock()
+	defer client.prgsMutex.Unlock()
+	return client.config
+}
+
+func (client *Client) setConfig(config *Config) {
+	client.prgsMutex.Lock()
+	defer client.prgsMutex.Unlock()
+	client.config = config
+}
+
+// removeProgram stops the program and its connector
+func (client *Client) removeProgram(prg *Program) {
+	client.prgsMutex.Lock()
+	prgs := []*Program{}
+	for _, other := range client.prgs {
+		if other != prg {
+			prgs = append(prgs, other)
+		}
+	}
+	client.prgs = prgs
+	client.prgsMutex.Unlock()
+	prg.Remove()
+}
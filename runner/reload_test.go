@@ -0,0 +1,314 @@
+package runner_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("DiffConfigs", func() {
+	var oldConfig *runner.Config
+
+	BeforeEach(func() {
+		oldConfig = &runner.Config{
+			Tag:  "v1.0.0",
+			Args: []string{"--inspect"},
+			Env:  map[string]string{"API_KEY": "old-secret"},
+		}
+	})
+
+	Describe("when nothing changed", func() {
+		It("should return no changes", func() {
+			newConfig := *oldConfig
+			Expect(runner.DiffConfigs(oldConfig, &newConfig)).To(BeEmpty())
+		})
+	})
+
+	Describe("when fields changed", func() {
+		var changes []*runner.ConfigChange
+
+		BeforeEach(func() {
+			newConfig := *oldConfig
+			newConfig.Tag = "v2.0.0"
+			newConfig.Args = []string{}
+			newConfig.Env = map[string]string{"API_KEY": "new-secret"}
+			changes = runner.DiffConfigs(oldConfig, &newConfig)
+		})
+
+		It("should sort the changes by field", func() {
+			Expect(changes).To(HaveLen(3))
+			Expect(changes[0].Field).To(Equal("Args"))
+			Expect(changes[1].Field).To(Equal("Env"))
+			Expect(changes[2].Field).To(Equal("Tag"))
+		})
+
+		It("should format the values as json", func() {
+			Expect(changes[0].Old).To(Equal(`["--inspect"]`))
+			Expect(changes[0].New).To(Equal(`[]`))
+			Expect(changes[2].Old).To(Equal(`"v1.0.0"`))
+			Expect(changes[2].New).To(Equal(`"v2.0.0"`))
+		})
+
+		It("should hide the env values", func() {
+			Expect(changes[1].Old).To(Equal("[hidden]"))
+			Expect(changes[1].New).To(Equal("[hidden]"))
+		})
+	})
+})
+
+var _ = Describe("Program.Reload", func() {
+	var dir string
+	var config *runner.Config
+	var prg *runner.Program
+
+	pid := func() int {
+		return prg.Status().PID
+	}
+
+	BeforeEach(func() {
+		config = newTestConfig("reload")
+		dir = config.Dir
+		config.DisplayName = "Some Connector"
+		config.Command = "/bin/sh"
+		config.Args = []string{"-c", "exec sleep 10"}
+		var err error
+		prg, err = runner.NewTestProgram(config, newFakeConnector("1.0.0"), &fakeStatus{}, &fakeUpdateConnector{tag: "v1.0.0"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(prg.Start(nil)).To(Succeed())
+		Eventually(pid).ShouldNot(BeZero())
+	})
+
+	AfterEach(func() {
+		prg.Remove()
+		os.RemoveAll(dir)
+	})
+
+	Describe("when nothing changed", func() {
+		It("should keep the connector running", func() {
+			oldPID := pid()
+			newConfig := *config
+			Expect(prg.Reload(&newConfig)).To(Succeed())
+			Consistently(pid).Should(Equal(oldPID))
+		})
+	})
+
+	Describe("when a field of the connector changed", func() {
+		It("should restart the connector with it", func() {
+			oldPID := pid()
+			newConfig := *config
+			newConfig.Args = []string{"-c", "echo reloaded; exec sleep 10"}
+			Expect(prg.Reload(&newConfig)).To(Succeed())
+			Eventually(pid).ShouldNot(Equal(oldPID))
+			Eventually(func() string {
+				return prg.TailLogs(1)
+			}).Should(ContainSubstring("reloaded"))
+		})
+	})
+
+	Describe("when a field of the runner changed", func() {
+		It("should apply it without a restart", func() {
+			oldPID := pid()
+			newConfig := *config
+			newConfig.MaxRestarts = 3
+			newConfig.DisableSelfUpdate = true
+			Expect(prg.Reload(&newConfig)).To(Succeed())
+			Expect(newConfig.MaxRestarts).To(Equal(3))
+			Expect(newConfig.DisableSelfUpdate).To(BeTrue())
+			Consistently(pid).Should(Equal(oldPID))
+		})
+	})
+
+	Describe("when a field of ignition changed", func() {
+		It("should keep the current value", func() {
+			oldPID := pid()
+			newConfig := *config
+			newConfig.DisplayName = "Another Connector"
+			Expect(prg.Reload(&newConfig)).To(Succeed())
+			Expect(newConfig.DisplayName).To(Equal("Some Connector"))
+			Consistently(pid).Should(Equal(oldPID))
+		})
+	})
+
+	Describe("when the new config is invalid", func() {
+		It("should keep the current config", func() {
+			oldPID := pid()
+			newConfig := *config
+			newConfig.Args = []string{"-c", "exit 1"}
+			newConfig.RestartPolicy = "sometimes"
+			Expect(prg.Reload(&newConfig)).NotTo(Succeed())
+			Consistently(pid).Should(Equal(oldPID))
+		})
+	})
+})
+
+var _ = Describe("Client.Reload", func() {
+	var dir string
+	var programs map[string]*runner.Program
+	var programsMutex sync.Mutex
+	var sut *runner.Client
+
+	writeConfig := func(path string, config *runner.Config) {
+		data, err := json.Marshal(config)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(path, data, 0644)).To(Succeed())
+	}
+
+	connectorConfig := func(name string, args ...string) *runner.Config {
+		connectorDir := filepath.Join(dir, name)
+		Expect(os.MkdirAll(connectorDir, 0755)).To(Succeed())
+		err := ioutil.WriteFile(filepath.Join(connectorDir, "meshblu.json"), []byte(`{"uuid":"some-uuid","token":"some-token"}`), 0600)
+		Expect(err).NotTo(HaveOccurred())
+		if len(args) == 0 {
+			args = []string{"-c", "exec sleep 10"}
+		}
+		config := &runner.Config{
+			ServiceName:   "MeshbluConnector-" + name,
+			ConnectorName: "meshblu-connector-" + name,
+			GithubSlug:    "octoblu/meshblu-connector-" + name,
+			Dir:           connectorDir,
+			Command:       "/bin/sh",
+			Args:          args,
+			Stdout:        filepath.Join(connectorDir, "connector.log"),
+			Stderr:        filepath.Join(connectorDir, "connector-error.log"),
+		}
+		writeConfig(filepath.Join(connectorDir, "service.json"), config)
+		return config
+	}
+
+	multiConfig := func(names ...string) *runner.Config {
+		config := &runner.Config{ServiceName: "MeshbluConnectors"}
+		for _, name := range names {
+			config.Connectors = append(config.Connectors, filepath.Join(dir, name))
+		}
+		return config
+	}
+
+	getProgram := func(serviceName string) *runner.Program {
+		programsMutex.Lock()
+		defer programsMutex.Unlock()
+		return programs[serviceName]
+	}
+
+	running := func() []string {
+		statuses, err := sut.Status()
+		if err != nil {
+			return nil
+		}
+		names := []string{}
+		for _, status := range statuses {
+			if status.Running {
+				names = append(names, status.Name)
+			}
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	start := func(config *runner.Config) {
+		sut = runner.NewTestClient(config, func(config *runner.Config) (*runner.Program, error) {
+			prg, err := runner.NewTestProgram(config, newFakeConnector("1.0.0"), &fakeStatus{}, &fakeUpdateConnector{tag: "v1.0.0"})
+			if err != nil {
+				return nil, err
+			}
+			programsMutex.Lock()
+			defer programsMutex.Unlock()
+			programs[config.ServiceName] = prg
+			return prg, nil
+		})
+		Expect(sut.StartPrograms()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "reload")
+		Expect(err).NotTo(HaveOccurred())
+		programsMutex.Lock()
+		programs = map[string]*runner.Program{}
+		programsMutex.Unlock()
+	})
+
+	AfterEach(func() {
+		sut.Shutdown()
+		programsMutex.Lock()
+		for _, prg := range programs {
+			prg.Remove()
+		}
+		programsMutex.Unlock()
+		os.RemoveAll(dir)
+	})
+
+	Describe("with a single connector", func() {
+		var config *runner.Config
+
+		BeforeEach(func() {
+			config = connectorConfig("single")
+			start(config)
+			Eventually(running).Should(Equal([]string{"MeshbluConnector-single"}))
+		})
+
+		It("should reload the program", func() {
+			oldPID := getProgram("MeshbluConnector-single").Status().PID
+			newConfig := *config
+			newConfig.Args = []string{"-c", "exec sleep 20"}
+			Expect(sut.Reload(&newConfig)).To(Succeed())
+			Eventually(func() int {
+				return getProgram("MeshbluConnector-single").Status().PID
+			}).ShouldNot(Equal(oldPID))
+		})
+
+		It("should refuse an invalid config", func() {
+			newConfig := *config
+			newConfig.Stdout = ""
+			Expect(sut.Reload(&newConfig)).To(MatchError(ContainSubstring("Stdout is required")))
+		})
+	})
+
+	Describe("with several connectors", func() {
+		BeforeEach(func() {
+			connectorConfig("one")
+			connectorConfig("two")
+			start(multiConfig("one", "two"))
+			Eventually(running).Should(Equal([]string{"MeshbluConnector-one", "MeshbluConnector-two"}))
+		})
+
+		Describe("when a connector is removed and another added", func() {
+			BeforeEach(func() {
+				connectorConfig("three")
+				Expect(sut.Reload(multiConfig("one", "three"))).To(Succeed())
+			})
+
+			It("should start the added one", func() {
+				Eventually(running).Should(Equal([]string{"MeshbluConnector-one", "MeshbluConnector-three"}))
+			})
+
+			It("should stop the removed one", func() {
+				Eventually(func() bool {
+					return getProgram("MeshbluConnector-two").Status().Running
+				}).Should(BeFalse())
+				_, err := sut.TailLogs("MeshbluConnector-two", 1)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Describe("when a connector changed", func() {
+			It("should only restart that one", func() {
+				onePID := getProgram("MeshbluConnector-one").Status().PID
+				twoPID := getProgram("MeshbluConnector-two").Status().PID
+				connectorConfig("two", "-c", "exec sleep 20")
+				Expect(sut.Reload(multiConfig("one", "two"))).To(Succeed())
+				Eventually(func() int {
+					return getProgram("MeshbluConnector-two").Status().PID
+				}).ShouldNot(Equal(twoPID))
+				Expect(getProgram("MeshbluConnector-one").Status().PID).To(Equal(onePID))
+			})
+		})
+	})
+})
@@ -0,0 +1,220 @@
+package runner_test
+
+import (
+	"os"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RestartLoop", func() {
+	var dir string
+	var config *runner.Config
+	var device *fakeConnector
+	var statusDevice *fakeStatus
+	var reporter *fakeReporter
+	var prg *runner.Program
+
+	start := func(script string) {
+		var err error
+		config.Args = []string{"-c", script}
+		prg, err = runner.NewTestProgram(config, device, statusDevice, &fakeUpdateConnector{tag: "v1.0.0"})
+		Expect(err).NotTo(HaveOccurred())
+		prg.SetErrorReporter(reporter)
+		Expect(prg.Start(nil)).To(Succeed())
+	}
+
+	state := func() string {
+		return statusDevice.getIgnition().State
+	}
+
+	BeforeEach(func() {
+		config = newTestConfig("restart")
+		dir = config.Dir
+		config.Command = "/bin/sh"
+		config.BackoffMin = runner.Duration(10 * time.Millisecond)
+		config.BackoffMax = runner.Duration(10 * time.Millisecond)
+		device = newFakeConnector("1.0.0")
+		statusDevice = &fakeStatus{}
+		reporter = &fakeReporter{}
+	})
+
+	AfterEach(func() {
+		prg.Remove()
+		os.RemoveAll(dir)
+	})
+
+	Describe("when the connector keeps crashing", func() {
+		BeforeEach(func() {
+			config.MaxRestarts = 1
+			start("echo oops >&2; exit 1")
+		})
+
+		It("should reset the reported errors before every run", func() {
+			Eventually(state, 2*time.Second).Should(Equal(status.StateFailed))
+			Expect(reporter.getResets()).To(Equal(2))
+		})
+
+		It("should trip the circuit breaker and stay down", func() {
+			Eventually(state, 2*time.Second).Should(Equal(status.StateFailed))
+			ignition := statusDevice.getIgnition()
+			Expect(ignition.FailedReason).To(Equal("restarted more than 1 times within 10m0s"))
+			Expect(ignition.RestartCount).To(Equal(1))
+			Consistently(state).Should(Equal(status.StateFailed))
+		})
+
+		Describe("when it is restarted", func() {
+			It("should start it again", func() {
+				Eventually(state, 2*time.Second).Should(Equal(status.StateFailed))
+				prg.Restart()
+				Eventually(func() int {
+					return statusDevice.getIgnition().RestartCount
+				}).Should(BeNumerically(">", 1))
+			})
+		})
+	})
+
+	Describe("when the connector crashes after the StabilityWindow", func() {
+		BeforeEach(func() {
+			config.BackoffMin = runner.Duration(100 * time.Millisecond)
+			config.BackoffMax = runner.Duration(10 * time.Second)
+			config.StabilityWindow = runner.Duration(20 * time.Millisecond)
+			start("sleep 0.1; exit 1")
+		})
+
+		It("should reset the backoff", func() {
+			Eventually(func() int {
+				return len(statusDevice.getBackoffs())
+			}, 3*time.Second).Should(BeNumerically(">=", 3))
+			Expect(statusDevice.getBackoffs()[:3]).To(Equal([]int64{100, 100, 100}))
+		})
+	})
+
+	Describe("when the connector crashes within the StabilityWindow", func() {
+		BeforeEach(func() {
+			config.BackoffMin = runner.Duration(100 * time.Millisecond)
+			config.BackoffMax = runner.Duration(10 * time.Second)
+			config.StabilityWindow = runner.Duration(10 * time.Second)
+			start("sleep 0.1; exit 1")
+		})
+
+		It("should increase the backoff", func() {
+			Eventually(func() int {
+				return len(statusDevice.getBackoffs())
+			}, 3*time.Second).Should(BeNumerically(">=", 3))
+			Expect(statusDevice.getBackoffs()[:3]).To(Equal([]int64{100, 200, 400}))
+		})
+	})
+
+	Describe("when the connector exits cleanly", func() {
+		restartCount := func() int {
+			return statusDevice.getIgnition().RestartCount
+		}
+
+		Describe("with the always restart policy", func() {
+			It("should restart it", func() {
+				start("exit 0")
+				Eventually(restartCount, 2*time.Second).Should(BeNumerically(">", 1))
+			})
+		})
+
+		Describe("with the on-failure restart policy", func() {
+			It("should record the exit and keep it down", func() {
+				config.RestartPolicy = runner.RestartOnFailure
+				start("exit 0")
+				Eventually(state, 2*time.Second).Should(Equal(status.StateExited))
+				Consistently(state).Should(Equal(status.StateExited))
+				Expect(restartCount()).To(BeZero())
+				lastExit := statusDevice.getIgnition().LastExit
+				Expect(lastExit).NotTo(BeNil())
+				Expect(lastExit.Code).To(Equal(0))
+				Expect(lastExit.Reason).To(Equal("exited with code 0"))
+			})
+		})
+
+		Describe("with the never restart policy", func() {
+			It("should keep it down", func() {
+				config.RestartPolicy = runner.RestartNever
+				start("exit 0")
+				Eventually(state, 2*time.Second).Should(Equal(status.StateExited))
+				Consistently(restartCount).Should(BeZero())
+			})
+		})
+	})
+
+	Describe("when the connector crashes", func() {
+		Describe("with the on-failure restart policy", func() {
+			It("should restart it", func() {
+				config.RestartPolicy = runner.RestartOnFailure
+				start("exit 3")
+				Eventually(func() int {
+					return statusDevice.getIgnition().RestartCount
+				}, 2*time.Second).Should(BeNumerically(">", 1))
+			})
+		})
+
+		Describe("with the never restart policy", func() {
+			It("should record the exit and keep it down", func() {
+				config.RestartPolicy = runner.RestartNever
+				start("exit 3")
+				Eventually(state, 2*time.Second).Should(Equal(status.StateCrashed))
+				Consistently(state).Should(Equal(status.StateCrashed))
+				lastExit := statusDevice.getIgnition().LastExit
+				Expect(lastExit.Code).To(Equal(3))
+				Expect(lastExit.Reason).To(Equal("exited with code 3"))
+			})
+		})
+
+		Describe("when it is killed by a signal", func() {
+			It("should record the signal", func() {
+				config.RestartPolicy = runner.RestartNever
+				start("kill -TERM $$")
+				Eventually(state, 2*time.Second).Should(Equal(status.StateCrashed))
+				lastExit := statusDevice.getIgnition().LastExit
+				Expect(lastExit.Signal).To(Equal("terminated"))
+				Expect(lastExit.Reason).To(Equal("terminated by signal terminated"))
+			})
+		})
+	})
+
+	Describe("when the device is stopped", func() {
+		pid := func() int {
+			return prg.Status().PID
+		}
+
+		var oldPID int
+
+		BeforeEach(func() {
+			start("exec sleep 10")
+			Eventually(pid).ShouldNot(BeZero())
+			oldPID = pid()
+			device.set("1.0.0", true)
+			Expect(prg.CheckForChanges()).To(Succeed())
+		})
+
+		It("should terminate the connector and keep it down", func() {
+			Eventually(state).Should(Equal(status.StateStopped))
+			Expect(prg.Status().Running).To(BeFalse())
+			Expect(prg.Status().Stopped).To(BeTrue())
+			Expect(statusDevice.getStopped()).To(BeTrue())
+			Consistently(state).Should(Equal(status.StateStopped))
+			Expect(statusDevice.getBackoffs()).To(BeEmpty())
+		})
+
+		Describe("when it is started again", func() {
+			It("should relaunch the connector", func() {
+				Eventually(state).Should(Equal(status.StateStopped))
+				device.set("1.0.0", false)
+				Expect(prg.CheckForChanges()).To(Succeed())
+				Eventually(state).Should(Equal(status.StateRunning))
+				Expect(pid()).NotTo(Equal(oldPID))
+				Expect(prg.Status().Stopped).To(BeFalse())
+				Expect(statusDevice.getStopped()).To(BeFalse())
+			})
+		})
+	})
+})
@@ -1,46 +1,73 @@
 package runner
 
 import (
-	"path/filepath"
+	"bytes"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/kardianos/service"
 	"github.com/octoblu/go-meshblu-connector-ignition/connector"
 	"github.com/octoblu/go-meshblu-connector-ignition/logger"
 	"github.com/octoblu/go-meshblu-connector-ignition/status"
+	"github.com/octoblu/go-meshblu-connector-ignition/subscription"
 	"github.com/octoblu/go-meshblu-connector-ignition/updateconnector"
 	"github.com/octoblu/go-meshblu/http/meshblu"
 )
 
 var mainLogger logger.MainLogger
 
+// setupRetryDelay is how long to wait before setting up a connector again
+var setupRetryDelay = 10 * time.Second
+
 // Runner defines the interface to run a Cmd
 type Runner interface {
 	Start() error
 	Shutdown() error
 	IsRunning() bool
+	Status() ([]*Status, error)
+	Restart(connector string) error
+	StopChild(connector string) error
+	StartChild(connector string) error
+	TailLogs(connector string, lines int) (string, error)
+	Reload(config *Config) error
 }
 
 // Client defines the stucture of the client
 type Client struct {
-	config    *Config
-	prg       *Program
-	isRunning bool
+	config          *Config
+	prgs            []*Program
+	prgsMutex       sync.Mutex
+	isRunning       bool
+	started         bool
+	ignitionVersion string
+	createProgram   func(config *Config) (*Program, error)
+	setups          map[string]chan bool
+	done            chan bool
 }
 
 // New creates a new instance of runner
-func New(config *Config) Runner {
-	return &Client{config, nil, false}
+func New(config *Config, ignitionVersion string) Runner {
+	client := &Client{
+		config:          config,
+		isRunning:       false,
+		ignitionVersion: ignitionVersion,
+		setups:          map[string]chan bool{},
+		done:            make(chan bool),
+	}
+	client.createProgram = client.newProgram
+	return client
 }
 
-// Start runs the connector
+// Start runs the connector, or each of the connectors
+// listed in the config as an independent program
 func (client *Client) Start() error {
 	if mainLogger == nil {
 		mainLogger = logger.GetMainLogger()
 	}
-	prg, err := NewProgram(client.config)
+	configs, err := client.config.GetConnectorConfigs()
 	if err != nil {
-		mainLogger.Error("runner", "Error creating new program", err)
+		mainLogger.Error("runner", "Error getting connector configs", err)
 		return err
 	}
 
@@ -50,19 +77,177 @@ func (client *Client) Start() error {
 		Description: client.config.Description,
 	}
 
-	srv, err := service.New(prg, srvConfig)
+	srv, err := service.New(&programGroup{client}, srvConfig)
 	if err != nil {
 		mainLogger.Error("runner", "Error getting service", err)
 		return err
 	}
 
-	meshbluConfigPath := filepath.Join(client.config.Dir, "meshblu.json")
-	meshbluClient, uuid, err := meshblu.NewClient(meshbluConfigPath)
+	err = client.addPrograms(configs)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		mainLogger.Info("runner", "service about to start")
+		err := srv.Run()
+		if err != nil {
+			mainLogger.Error("runner", "service run error", err)
+		}
+		client.isRunning = false
+	}()
+	return nil
+}
+
+// addPrograms sets up the connector, or starts setting
+// up each of the connectors in the multi connector mode
+func (client *Client) addPrograms(configs []*Config) error {
+	if !client.config.IsMulti() {
+		prg, err := client.createProgram(configs[0])
+		if err != nil {
+			return err
+		}
+		client.addProgram(prg, nil)
+		return nil
+	}
+	for _, config := range configs {
+		client.setupProgram(config)
+	}
+	return nil
+}
+
+// setupProgram sets up a connector of the multi connector mode in
+// the background, canceling a setup of it that is still retrying
+func (client *Client) setupProgram(config *Config) {
+	client.prgsMutex.Lock()
+	defer client.prgsMutex.Unlock()
+	if cancel, ok := client.setups[config.ServiceName]; ok {
+		close(cancel)
+	}
+	cancel := make(chan bool)
+	client.setups[config.ServiceName] = cancel
+	go client.startProgram(config, cancel)
+}
+
+// cancelSetup stops setting up the connector
+func (client *Client) cancelSetup(serviceName string) {
+	client.prgsMutex.Lock()
+	defer client.prgsMutex.Unlock()
+	if cancel, ok := client.setups[serviceName]; ok {
+		close(cancel)
+		delete(client.setups, serviceName)
+	}
+}
+
+// getSetups returns the connectors that are still being set up
+func (client *Client) getSetups() map[string]bool {
+	client.prgsMutex.Lock()
+	defer client.prgsMutex.Unlock()
+	setups := map[string]bool{}
+	for serviceName := range client.setups {
+		setups[serviceName] = true
+	}
+	return setups
+}
+
+// startProgram sets up a connector of the multi connector mode,
+// retrying without holding up the others until it succeeds, the
+// setup is canceled or the client stops
+func (client *Client) startProgram(config *Config, cancel chan bool) {
+	for {
+		prg, err := client.createProgram(config)
+		if err == nil {
+			if !client.addProgram(prg, cancel) {
+				mainLogger.Info("runner", fmt.Sprintf("%s is no longer needed, removing it", config.ServiceName))
+				prg.Remove()
+			}
+			return
+		}
+		mainLogger.Error("runner", fmt.Sprintf("Error setting up %s (will retry soon)", config.ServiceName), err)
+		select {
+		case <-cancel:
+			return
+		case <-client.done:
+			return
+		case <-time.After(setupRetryDelay):
+		}
+	}
+}
+
+// addProgram adds the program, starting it when the service has started,
+// it returns false once the client stopped or the setup was canceled
+func (client *Client) addProgram(prg *Program, cancel chan bool) bool {
+	client.prgsMutex.Lock()
+	defer client.prgsMutex.Unlock()
+	select {
+	case <-client.done:
+		return false
+	case <-cancel:
+		return false
+	default:
+	}
+	if cancel != nil {
+		delete(client.setups, prg.getConfig().ServiceName)
+	}
+	client.prgs = append(client.prgs, prg)
+	if client.started {
+		prg.Start(nil)
+	}
+	return true
+}
+
+// stop keeps the connectors that are still being set up from starting
+func (client *Client) stop() {
+	client.prgsMutex.Lock()
+	defer client.prgsMutex.Unlock()
+	client.started = false
+	select {
+	case <-client.done:
+	default:
+		close(client.done)
+	}
+}
+
+// newProgram creates the program of a connector with
+// its own meshblu client, status device and updater
+func (client *Client) newProgram(config *Config) (*Program, error) {
+	prg, err := NewProgram(config)
+	if err != nil {
+		mainLogger.Error("runner", "Error creating new program", err)
+		return nil, err
+	}
+	err = client.connectProgram(prg, config)
+	if err != nil {
+		prg.Stop(nil)
+		return nil, err
+	}
+	return prg, nil
+}
+
+// connectProgram gives the program its meshblu client, status device
+// and updater, the caller stops the program when it fails
+func (client *Client) connectProgram(prg *Program, config *Config) error {
+	var err error
+	secretValues := map[string]string{}
+	if prg.options.secretStore != nil {
+		secretValues, err = prg.options.secretStore.Read()
+		if err != nil {
+			mainLogger.Error("runner", "Error reading the secret store", err)
+			return err
+		}
+	}
+	credentials, err := config.getMeshbluCredentials(secretValues)
+	if err != nil {
+		mainLogger.Error("runner", "Error getting meshblu credentials", err)
+		return err
+	}
+	meshbluClient, err := meshblu.Dial(credentials.uri)
 	if err != nil {
 		mainLogger.Error("runner", "Error getting meshblu client", err)
 		return err
 	}
-	connectorClient, err := connector.New(meshbluClient, uuid, client.config.Tag)
+	meshbluClient.SetAuth(credentials.uuid, credentials.token)
+	connectorClient, err := connector.New(meshbluClient, credentials.uuid, config.Tag)
 	if err != nil {
 		mainLogger.Error("runner", "Error connector client new", err)
 		return err
@@ -83,50 +268,187 @@ func (client *Client) Start() error {
 	}
 
 	prg.connector = connectorClient
+	prg.meshbluClient = meshbluClient
+	prg.deviceUUID = credentials.uuid
+
+	if config.Subscribe {
+		prg.stream = subscription.New(credentials.uri, credentials.uuid, credentials.token)
+	}
 
-	status, err := status.New(meshbluClient, connectorClient.StatusUUID())
+	statusClient, err := status.New(meshbluClient, connectorClient.StatusUUID())
 	if err != nil {
 		mainLogger.Error("runner", "error getting status device", err)
 		return err
 	}
-	err = status.ResetErrors()
+	err = statusClient.ResetErrors()
 	if err != nil {
 		mainLogger.Error("runner", "error resetting errors on status device", err)
 	} else {
 		mainLogger.Info("runner", "reset errors on status device")
 	}
-	prg.status = status
+	prg.status = statusClient
+	prg.errorReporter = status.NewErrorReporter(statusClient, prg.config.GetReporterOptions())
+	prg.telemetry = newTelemetry(statusClient, client.ignitionVersion)
 
 	githubSlug := prg.config.GithubSlug
 	connectorName := prg.config.ConnectorName
 	dir := prg.config.Dir
-	uc, err := updateconnector.New(githubSlug, connectorName, dir, nil, nil)
+	verifyOptions := updateconnector.VerifyOptions{
+		PublicKey:    prg.config.PublicKey,
+		SkipChecksum: prg.config.SkipChecksum,
+	}
+	downloadURL := prg.config.ConnectorDownloadURL
+	uc, err := updateconnector.New(githubSlug, connectorName, dir, downloadURL, verifyOptions, nil, nil)
 	if err != nil {
 		mainLogger.Error("runner", "Error getting update connector", err)
 		return err
 	}
 	prg.uc = uc
-	client.prg = prg
-
-	go func() {
-		mainLogger.Info("runner", "service about to start")
-		err := srv.Run()
-		if err != nil {
-			mainLogger.Error("runner", "service run error", err)
-		}
-		client.isRunning = false
-	}()
+	prg.rolledBackTag = uc.RolledBackTag()
 	return nil
 }
 
 // Shutdown will kill the connector process
 func (client *Client) Shutdown() error {
-	err := client.prg.Stop(nil)
+	client.stop()
+	var lastErr error
+	for _, prg := range client.getPrograms("") {
+		err := prg.Stop(nil)
+		if err != nil {
+			lastErr = err
+		}
+	}
 	client.isRunning = false
-	return err
+	return lastErr
 }
 
 // IsRunning will return true if the connector is reported as running
 func (client *Client) IsRunning() bool {
 	return client.isRunning
 }
+
+// Status returns the current state of the connectors
+func (client *Client) Status() ([]*Status, error) {
+	prgs := client.getPrograms("")
+	if len(prgs) == 0 {
+		return nil, errNotStarted()
+	}
+	statuses := []*Status{}
+	for _, prg := range prgs {
+		statuses = append(statuses, prg.Status())
+	}
+	return statuses, nil
+}
+
+// Restart restarts the connector without waiting for the backoff
+func (client *Client) Restart(connector string) error {
+	prgs, err := client.findPrograms(connector)
+	if err != nil {
+		return err
+	}
+	for _, prg := range prgs {
+		prg.Restart()
+	}
+	return nil
+}
+
+// StopChild stops the connector process and keeps it down
+func (client *Client) StopChild(connector string) error {
+	prgs, err := client.findPrograms(connector)
+	if err != nil {
+		return err
+	}
+	for _, prg := range prgs {
+		prg.StopChild()
+	}
+	return nil
+}
+
+// StartChild starts the connector process after StopChild
+func (client *Client) StartChild(connector string) error {
+	prgs, err := client.findPrograms(connector)
+	if err != nil {
+		return err
+	}
+	for _, prg := range prgs {
+		prg.StartChild()
+	}
+	return nil
+}
+
+// TailLogs returns the last lines of the connector output
+func (client *Client) TailLogs(connector string, lines int) (string, error) {
+	prgs, err := client.findPrograms(connector)
+	if err != nil {
+		return "", err
+	}
+	if len(prgs) == 1 && !client.getConfig().IsMulti() {
+		return prgs[0].TailLogs(lines), nil
+	}
+	var buf bytes.Buffer
+	for _, prg := range prgs {
+		fmt.Fprintf(&buf, "### %s ###\n", prg.getConfig().ServiceName)
+		fmt.Fprintln(&buf, prg.TailLogs(lines))
+	}
+	return buf.String(), nil
+}
+
+// getPrograms returns the programs with the ServiceName or
+// ConnectorName, or all of them when the name is empty
+func (client *Client) getPrograms(name string) []*Program {
+	client.prgsMutex.Lock()
+	defer client.prgsMutex.Unlock()
+	prgs := []*Program{}
+	for _, prg := range client.prgs {
+		config := prg.getConfig()
+		if name == "" || config.ServiceName == name || config.ConnectorName == name {
+			prgs = append(prgs, prg)
+		}
+	}
+	return prgs
+}
+
+func (client *Client) findPrograms(name string) ([]*Program, error) {
+	prgs := client.getPrograms(name)
+	if len(prgs) > 0 {
+		return prgs, nil
+	}
+	if name == "" {
+		return nil, errNotStarted()
+	}
+	return nil, fmt.Errorf("connector %s is not running", name)
+}
+
+// programGroup runs all of the programs as one service
+type programGroup struct {
+	client *Client
+}
+
+// Start starts the programs
+func (group *programGroup) Start(srv service.Service) error {
+	client := group.client
+	client.prgsMutex.Lock()
+	defer client.prgsMutex.Unlock()
+	client.started = true
+	for _, prg := range client.prgs {
+		prg.Start(srv)
+	}
+	return nil
+}
+
+// Stop stops the programs
+func (group *programGroup) Stop(srv service.Service) error {
+	group.client.stop()
+	var lastErr error
+	for _, prg := range group.client.getPrograms("") {
+		err := prg.Stop(srv)
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func errNotStarted() error {
+	return fmt.Errorf("connector has not been started")
+}
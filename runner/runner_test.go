@@ -0,0 +1,170 @@
+package runner_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var dir string
+	var mutex sync.Mutex
+	var programs []*runner.Program
+	var attempts map[string]int
+	var failing map[string]bool
+	var sut *runner.Client
+
+	connectorConfig := func(name string) string {
+		connectorDir := filepath.Join(dir, name)
+		Expect(os.MkdirAll(connectorDir, 0755)).To(Succeed())
+		data, err := json.Marshal(&runner.Config{
+			ServiceName:   "MeshbluConnector-" + name,
+			ConnectorName: "meshblu-connector-" + name,
+			GithubSlug:    "octoblu/meshblu-connector-" + name,
+			Dir:           connectorDir,
+			Command:       "/bin/sh",
+			Args:          []string{"-c", "exec sleep 10"},
+			Stdout:        filepath.Join(connectorDir, "connector.log"),
+			Stderr:        filepath.Join(connectorDir, "connector-error.log"),
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(connectorDir, "meshblu.json"), []byte(`{"uuid":"some-uuid","token":"some-token"}`), 0600)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(connectorDir, "service.json"), data, 0644)).To(Succeed())
+		return connectorDir
+	}
+
+	multiConfig := func(names ...string) *runner.Config {
+		config := &runner.Config{ServiceName: "MeshbluConnectors"}
+		for _, name := range names {
+			config.Connectors = append(config.Connectors, filepath.Join(dir, name))
+		}
+		return config
+	}
+
+	setFailing := func(serviceName string, fail bool) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		failing[serviceName] = fail
+	}
+
+	getAttempts := func(serviceName string) func() int {
+		return func() int {
+			mutex.Lock()
+			defer mutex.Unlock()
+			return attempts[serviceName]
+		}
+	}
+
+	runningPrograms := func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		count := 0
+		for _, prg := range programs {
+			if prg.Status().Running {
+				count++
+			}
+		}
+		return count
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "runner")
+		Expect(err).NotTo(HaveOccurred())
+		mutex.Lock()
+		programs = nil
+		attempts = map[string]int{}
+		failing = map[string]bool{}
+		mutex.Unlock()
+		connectorConfig("one")
+		connectorConfig("two")
+		sut = runner.NewTestClient(multiConfig("one", "two"), func(config *runner.Config) (*runner.Program, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			attempts[config.ServiceName]++
+			if failing[config.ServiceName] {
+				return nil, errors.New("meshblu is unavailable")
+			}
+			prg, err := runner.NewTestProgram(config, newFakeConnector("1.0.0"), &fakeStatus{}, &fakeUpdateConnector{tag: "v1.0.0"})
+			if err != nil {
+				return nil, err
+			}
+			programs = append(programs, prg)
+			return prg, nil
+		})
+	})
+
+	AfterEach(func() {
+		sut.Shutdown()
+		mutex.Lock()
+		for _, prg := range programs {
+			prg.Remove()
+		}
+		mutex.Unlock()
+		os.RemoveAll(dir)
+	})
+
+	Describe("when a connector cannot be set up", func() {
+		BeforeEach(func() {
+			setFailing("MeshbluConnector-two", true)
+			Expect(sut.StartPrograms()).To(Succeed())
+			Eventually(getAttempts("MeshbluConnector-two")).Should(BeNumerically(">", 1))
+		})
+
+		It("should start the others", func() {
+			Eventually(runningPrograms).Should(Equal(1))
+		})
+
+		It("should start it once it can be set up", func() {
+			setFailing("MeshbluConnector-two", false)
+			Eventually(runningPrograms).Should(Equal(2))
+		})
+
+		Describe("when the client is shut down", func() {
+			BeforeEach(func() {
+				Eventually(runningPrograms).Should(Equal(1))
+				Expect(sut.Shutdown()).To(Succeed())
+				setFailing("MeshbluConnector-two", false)
+			})
+
+			It("should stop retrying", func() {
+				attemptsAtShutdown := getAttempts("MeshbluConnector-two")()
+				Consistently(getAttempts("MeshbluConnector-two")).Should(BeNumerically("<=", attemptsAtShutdown+1))
+			})
+
+			It("should not start it", func() {
+				Consistently(func() int {
+					mutex.Lock()
+					defer mutex.Unlock()
+					for _, prg := range programs {
+						if prg.Status().Name == "MeshbluConnector-two" && prg.Status().Running {
+							return 1
+						}
+					}
+					return 0
+				}).Should(BeZero())
+			})
+		})
+
+		Describe("when it is removed from the config", func() {
+			BeforeEach(func() {
+				Expect(sut.Reload(multiConfig("one"))).To(Succeed())
+				setFailing("MeshbluConnector-two", false)
+			})
+
+			It("should stop retrying and not start it", func() {
+				attemptsAtRemoval := getAttempts("MeshbluConnector-two")()
+				Consistently(getAttempts("MeshbluConnector-two")).Should(BeNumerically("<=", attemptsAtRemoval+1))
+				Consistently(runningPrograms).Should(Equal(1))
+			})
+		})
+	})
+})
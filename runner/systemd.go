@@ -0,0 +1,108 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Systemd defines the systemd unit written by the install command
+type Systemd struct {
+	// Restart is when systemd restarts ignition,
+	// one of the systemd Restart values. Defaults to always
+	Restart string
+
+	// RestartSec is the delay before systemd restarts ignition, defaults to 5s
+	RestartSec Duration
+
+	// After, Wants and Requires are the units ignition depends on,
+	// After defaults to network-online.target, which is also wanted
+	// when ignition starts after it
+	After, Wants, Requires []string
+
+	// User and Group run ignition as another account, defaults to root
+	User, Group string
+
+	// WantedBy is the target that starts ignition, defaults to multi-user.target
+	WantedBy string
+}
+
+var systemdRestarts = []string{"no", "always", "on-success", "on-failure", "on-abnormal", "on-abort", "on-watchdog"}
+
+// GetRestart returns the systemd restart policy, defaults to always
+func (systemd *Systemd) GetRestart() (string, error) {
+	if systemd.Restart == "" {
+		return "always", nil
+	}
+	for _, restart := range systemdRestarts {
+		if systemd.Restart == restart {
+			return restart, nil
+		}
+	}
+	return "", fmt.Errorf("invalid systemd restart %s, must be one of %v", systemd.Restart, systemdRestarts)
+}
+
+// GetRestartSec returns the delay before systemd restarts ignition
+func (systemd *Systemd) GetRestartSec() time.Duration {
+	return systemd.RestartSec.OrDefault(5 * time.Second)
+}
+
+// GetAfter returns the units ignition starts after
+func (systemd *Systemd) GetAfter() []string {
+	if len(systemd.After) == 0 {
+		return []string{"network-online.target"}
+	}
+	return systemd.After
+}
+
+// GetWants returns the units ignition wants, with the
+// network-online.target when ignition starts after it
+func (systemd *Systemd) GetWants() []string {
+	const networkOnline = "network-online.target"
+	if !containsString(systemd.GetAfter(), networkOnline) || containsString(systemd.Wants, networkOnline) {
+		return systemd.Wants
+	}
+	return append([]string{networkOnline}, systemd.Wants...)
+}
+
+// Validate returns an error when the Restart is invalid or a
+// value has a line break, it would add lines to the unit
+func (systemd *Systemd) Validate() error {
+	_, err := systemd.GetRestart()
+	if err != nil {
+		return err
+	}
+	values := map[string][]string{
+		"After":    systemd.After,
+		"Wants":    systemd.Wants,
+		"Requires": systemd.Requires,
+		"User":     {systemd.User},
+		"Group":    {systemd.Group},
+		"WantedBy": {systemd.WantedBy},
+	}
+	for _, name := range []string{"After", "Wants", "Requires", "User", "Group", "WantedBy"} {
+		for _, value := range values[name] {
+			if strings.ContainsAny(value, "\r\n") {
+				return fmt.Errorf("%s %q must not contain a line break", name, value)
+			}
+		}
+	}
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, current := range values {
+		if current == value {
+			return true
+		}
+	}
+	return false
+}
+
+// GetWantedBy returns the target that starts ignition
+func (systemd *Systemd) GetWantedBy() string {
+	if systemd.WantedBy == "" {
+		return "multi-user.target"
+	}
+	return systemd.WantedBy
+}
@@ -0,0 +1,126 @@
+package runner
+
+import (
+	"sync"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+)
+
+// telemetry keeps the ignition section of the status device up to date,
+// sending only the latest state when the updates pile up
+type telemetry struct {
+	mutex     sync.Mutex
+	status    status.Status
+	ignition  status.Ignition
+	pending   chan bool
+	done      chan bool
+	closeOnce sync.Once
+}
+
+func newTelemetry(statusClient status.Status, ignitionVersion string) *telemetry {
+	t := &telemetry{
+		status: statusClient,
+		ignition: status.Ignition{
+			State:           status.StateStarting,
+			IgnitionVersion: ignitionVersion,
+		},
+		pending: make(chan bool, 1),
+		done:    make(chan bool),
+	}
+	go t.run()
+	return t
+}
+
+// update changes the ignition section and schedules sending it,
+// nothing is sent once the telemetry is closed
+func (t *telemetry) update(change func(ignition *status.Ignition)) {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	change(&t.ignition)
+	t.mutex.Unlock()
+	select {
+	case <-t.done:
+		return
+	default:
+	}
+	select {
+	case t.pending <- true:
+	default:
+	}
+}
+
+// setState changes the state of the connector
+func (t *telemetry) setState(state string) {
+	t.update(func(ignition *status.Ignition) {
+		ignition.State = state
+		if state != status.StateBackingOff {
+			ignition.NextBackoff = 0
+		}
+		if state != status.StateRunning {
+			ignition.PID = 0
+		}
+		if state != status.StateFailed {
+			ignition.FailedReason = ""
+		}
+	})
+}
+
+// get returns a copy of the ignition section
+func (t *telemetry) get() status.Ignition {
+	if t == nil {
+		return status.Ignition{}
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.ignition
+}
+
+// close sends the pending update and stops sending
+func (t *telemetry) close() {
+	if t == nil {
+		return
+	}
+	t.closeOnce.Do(func() {
+		close(t.done)
+	})
+}
+
+func (t *telemetry) run() {
+	for {
+		select {
+		case <-t.done:
+			select {
+			case <-t.pending:
+				t.send()
+			default:
+			}
+			return
+		case <-t.pending:
+		}
+		if !t.send() {
+			select {
+			case <-t.done:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+// send updates the status device, returning false when it failed
+func (t *telemetry) send() bool {
+	ignition := t.get()
+	err := t.status.UpdateIgnition(&ignition)
+	if err != nil {
+		mainLogger.Error("program.telemetry", "Error updating ignition on status device", err)
+		return false
+	}
+	return true
+}
+
+func toMillis(timestamp time.Time) int64 {
+	return timestamp.UnixNano() / int64(time.Millisecond)
+}
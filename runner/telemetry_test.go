@@ -0,0 +1,93 @@
+package runner_test
+
+import (
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Telemetry", func() {
+	var statusDevice *fakeStatus
+	var sut *runner.Telemetry
+
+	state := func() string {
+		return statusDevice.getIgnition().State
+	}
+
+	BeforeEach(func() {
+		statusDevice = &fakeStatus{}
+	})
+
+	JustBeforeEach(func() {
+		sut = runner.NewTelemetry(statusDevice)
+	})
+
+	AfterEach(func() {
+		sut.Close()
+	})
+
+	Describe("when the state changes", func() {
+		JustBeforeEach(func() {
+			sut.Update(func(ignition *status.Ignition) {
+				ignition.State = status.StateFailed
+				ignition.PID = 1234
+				ignition.NextBackoff = 1000
+				ignition.FailedReason = "too many restarts"
+			})
+			Eventually(state).Should(Equal(status.StateFailed))
+			sut.SetState(status.StateRunning)
+		})
+
+		It("should send the ignition section", func() {
+			Eventually(state).Should(Equal(status.StateRunning))
+			Expect(statusDevice.getIgnition().IgnitionVersion).To(Equal("1.2.3"))
+		})
+
+		It("should clear the fields of the previous state", func() {
+			Eventually(state).Should(Equal(status.StateRunning))
+			ignition := statusDevice.getIgnition()
+			Expect(ignition.PID).To(Equal(1234))
+			Expect(ignition.NextBackoff).To(BeZero())
+			Expect(ignition.FailedReason).To(BeEmpty())
+		})
+	})
+
+	Describe("when the updates pile up", func() {
+		BeforeEach(func() {
+			statusDevice.block = make(chan bool)
+		})
+
+		It("should send only the latest state", func() {
+			sut.SetState(status.StateUpdating)
+			time.Sleep(50 * time.Millisecond)
+			sut.SetState(status.StateStarting)
+			sut.SetState(status.StateRunning)
+			sut.SetState(status.StateCrashed)
+			close(statusDevice.block)
+			Eventually(state).Should(Equal(status.StateCrashed))
+			Consistently(statusDevice.getIgnitionUpdates).Should(Equal(2))
+		})
+	})
+
+	Describe("when it is closed", func() {
+		It("should send the pending update", func() {
+			statusDevice.block = make(chan bool)
+			sut.SetState(status.StateUpdating)
+			time.Sleep(50 * time.Millisecond)
+			sut.SetState(status.StateStopped)
+			sut.Close()
+			close(statusDevice.block)
+			Eventually(state).Should(Equal(status.StateStopped))
+		})
+
+		It("should stop sending", func() {
+			sut.Close()
+			sut.SetState(status.StateRunning)
+			Consistently(statusDevice.getIgnitionUpdates).Should(BeZero())
+		})
+	})
+})
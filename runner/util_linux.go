@@ -1,7 +1,96 @@
 package runner
 
-import "syscall"
+import (
+	"fmt"
+	"os/user"
+	"strconv"
+	"syscall"
+)
 
-func sysProcAttrForOS() *syscall.SysProcAttr {
-	return nil
+// sysProcAttrForOS runs the connector as the configured User and Group,
+// also returning the HOME, USER and LOGNAME env of the account
+func sysProcAttrForOS(config *Config) (*syscall.SysProcAttr, []string, error) {
+	if config.User == "" && config.Group == "" && len(config.Groups) == 0 {
+		return nil, nil, nil
+	}
+	credential := &syscall.Credential{
+		Uid: uint32(syscall.Getuid()),
+		Gid: uint32(syscall.Getgid()),
+	}
+	env := []string{}
+	if config.User != "" {
+		account, err := lookupUser(config.User)
+		if err != nil {
+			return nil, nil, err
+		}
+		credential.Uid, err = parseID(account.Uid)
+		if err != nil {
+			return nil, nil, err
+		}
+		credential.Gid, err = parseID(account.Gid)
+		if err != nil {
+			return nil, nil, err
+		}
+		env = append(env, SetEnv("HOME", account.HomeDir), SetEnv("USER", account.Username), SetEnv("LOGNAME", account.Username))
+	}
+	if config.Group != "" {
+		gid, err := lookupGroupID(config.Group)
+		if err != nil {
+			return nil, nil, err
+		}
+		credential.Gid = gid
+	}
+	for _, group := range config.Groups {
+		gid, err := lookupGroupID(group)
+		if err != nil {
+			return nil, nil, err
+		}
+		credential.Groups = append(credential.Groups, gid)
+	}
+	return &syscall.SysProcAttr{Credential: credential}, env, nil
+}
+
+// getCredentialIDs returns the uid and gid the connector runs as,
+// or -1 when it runs as the ignition user
+func getCredentialIDs(attr *syscall.SysProcAttr) (int, int) {
+	if attr == nil || attr.Credential == nil {
+		return -1, -1
+	}
+	return int(attr.Credential.Uid), int(attr.Credential.Gid)
+}
+
+func lookupUser(name string) (*user.User, error) {
+	account, err := user.Lookup(name)
+	if err == nil {
+		return account, nil
+	}
+	if _, isNumber := strconv.ParseUint(name, 10, 32); isNumber == nil {
+		account, err = user.LookupId(name)
+		if err == nil {
+			return account, nil
+		}
+	}
+	return nil, fmt.Errorf("user %s does not exist: %v", name, err)
+}
+
+func lookupGroupID(name string) (uint32, error) {
+	group, err := user.LookupGroup(name)
+	if err == nil {
+		return parseID(group.Gid)
+	}
+	if _, isNumber := strconv.ParseUint(name, 10, 32); isNumber == nil {
+		group, err = user.LookupGroupId(name)
+		if err == nil {
+			return parseID(group.Gid)
+		}
+	}
+	return 0, fmt.Errorf("group %s does not exist: %v", name, err)
+}
+
+func parseID(id string) (uint32, error) {
+	parsed, err := strconv.ParseUint(id, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(parsed), nil
 }
@@ -1,7 +1,17 @@
 package runner
 
-import "syscall"
+import (
+	"fmt"
+	"syscall"
+)
 
-func sysProcAttrForOS() *syscall.SysProcAttr {
-	return &syscall.SysProcAttr{HideWindow: true}
+func sysProcAttrForOS(config *Config) (*syscall.SysProcAttr, []string, error) {
+	if config.User != "" || config.Group != "" || len(config.Groups) > 0 || config.Umask != "" {
+		return nil, nil, fmt.Errorf("running as a user or with a umask is only supported on linux")
+	}
+	return &syscall.SysProcAttr{HideWindow: true}, nil, nil
+}
+
+func getCredentialIDs(attr *syscall.SysProcAttr) (int, int) {
+	return -1, -1
 }
@@ -0,0 +1,286 @@
+package runner
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/go-semver/semver"
+	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+)
+
+var githubSlugRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+// ValidationError lists every problem found in a config
+type ValidationError struct {
+	Problems []string
+
+	// Structural is set when a field holds an invalid value, the
+	// other problems are in the files and users the config refers
+	// to and can be fixed without changing it
+	Structural bool
+}
+
+func (err *ValidationError) Error() string {
+	return fmt.Sprintf("invalid config:\n  - %s", strings.Join(err.Problems, "\n  - "))
+}
+
+// Validate checks the config, and the configs of the connectors
+// in the multi connector mode, returning a *ValidationError with
+// all of the problems found
+func (config *Config) Validate() error {
+	problems := config.validateIgnition()
+	structural := len(problems) > 0
+	if !config.IsMulti() {
+		connectorProblems, connectorStructural := config.validateConnector()
+		problems = append(problems, connectorProblems...)
+		return newValidationError(problems, structural || connectorStructural)
+	}
+	if config.ServiceName == "" {
+		problems = append(problems, "ServiceName is required")
+		structural = true
+	}
+	configs, err := config.GetConnectorConfigs()
+	if err != nil {
+		problems = append(problems, err.Error())
+		return newValidationError(problems, true)
+	}
+	for _, connectorConfig := range configs {
+		connectorProblems, connectorStructural := connectorConfig.validateConnector()
+		for _, problem := range connectorProblems {
+			problems = append(problems, fmt.Sprintf("%s: %s", connectorConfig.ServiceName, problem))
+		}
+		structural = structural || connectorStructural
+	}
+	return newValidationError(problems, structural)
+}
+
+func newValidationError(problems []string, structural bool) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems, Structural: structural}
+}
+
+// validateIgnition returns the problems of the fields read by ignition itself
+func (config *Config) validateIgnition() []string {
+	problems := []string{}
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if config.LogFormat != "" && config.LogFormat != logger.TextFormat && config.LogFormat != logger.JSONFormat {
+		add("LogFormat %s must be %s or %s", config.LogFormat, logger.TextFormat, logger.JSONFormat)
+	}
+	if err := config.Systemd.Validate(); err != nil {
+		add("Systemd %v", err)
+	}
+	switch config.UpdateChannel {
+	case "", StableChannel, BetaChannel:
+	case PinnedChannel:
+		if config.PinnedVersion == "" {
+			add("UpdateChannel %s requires a PinnedVersion", PinnedChannel)
+		}
+	default:
+		add("UpdateChannel %s must be %s, %s or %s", config.UpdateChannel, StableChannel, BetaChannel, PinnedChannel)
+	}
+	versions := []struct{ name, value string }{
+		{"PinnedVersion", config.PinnedVersion},
+		{"IgnitionMinVersion", config.IgnitionMinVersion},
+		{"IgnitionMaxVersion", config.IgnitionMaxVersion},
+	}
+	for _, version := range versions {
+		if version.value == "" {
+			continue
+		}
+		if _, err := semver.NewVersion(strings.TrimPrefix(version.value, "v")); err != nil {
+			add("%s %s is not a semver version", version.name, version.value)
+		}
+	}
+	return problems
+}
+
+// validateConnector returns the problems of the config of a single
+// connector and whether one of them is structural
+func (config *Config) validateConnector() ([]string, bool) {
+	problems := []string{}
+	structural := false
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+	addInvalid := func(format string, args ...interface{}) {
+		structural = true
+		add(format, args...)
+	}
+
+	if config.ServiceName == "" {
+		addInvalid("ServiceName is required")
+	}
+	if config.ConnectorName == "" {
+		addInvalid("ConnectorName is required")
+	}
+	if config.GithubSlug == "" {
+		addInvalid("GithubSlug is required")
+	} else if !githubSlugRegexp.MatchString(config.GithubSlug) {
+		addInvalid("GithubSlug %s must be like owner/repo", config.GithubSlug)
+	}
+
+	dirOK := false
+	if config.Dir == "" {
+		addInvalid("Dir is required, it would default to the current directory")
+	} else if err := checkDir(config.Dir); err != nil {
+		add("Dir %v", err)
+	} else {
+		dirOK = true
+	}
+
+	if config.Stdout == "" {
+		addInvalid("Stdout is required")
+	} else if err := checkLogFile(config.Stdout); err != nil {
+		add("Stdout %v", err)
+	}
+	if config.Stderr == "" {
+		addInvalid("Stderr is required")
+	} else if err := checkLogFile(config.Stderr); err != nil {
+		add("Stderr %v", err)
+	}
+
+	if config.BinPath != "" {
+		if err := checkDir(config.BinPath); err != nil {
+			add("BinPath %v", err)
+		}
+	}
+	if dirOK {
+		if _, err := config.lookExecutable(config.GetCommand()); err != nil {
+			add("Command %s was not found in the BinPath, Dir or PATH", config.GetCommand())
+		}
+		if err := checkDir(config.GetWorkingDir()); err != nil {
+			add("WorkingDir %v", err)
+		}
+		for _, envFile := range config.EnvFiles {
+			if !filepath.IsAbs(envFile) {
+				envFile = filepath.Join(config.Dir, envFile)
+			}
+			if _, err := ioutil.ReadFile(envFile); err != nil {
+				add("EnvFiles %v", err)
+			}
+		}
+		if err := config.validateMeshbluConfig(); err != nil {
+			add("%s %v", MeshbluConfigName, err)
+		}
+	}
+
+	if config.SkipChecksum && config.PublicKey != "" {
+		addInvalid("SkipChecksum cannot be used with a PublicKey, the signature is only verified with the checksums")
+	}
+	if _, err := config.GetRestartPolicy(); err != nil {
+		addInvalid("RestartPolicy %v", err)
+	}
+	if _, err := config.GetUmask(); err != nil {
+		addInvalid("Umask %v", err)
+	}
+	if _, _, err := sysProcAttrForOS(config); err != nil {
+		add("User %v", err)
+	}
+	for i := range config.HealthChecks {
+		if _, err := config.HealthChecks[i].GetChecker(config.Dir); err != nil {
+			addInvalid("HealthChecks %v", err)
+		}
+	}
+	return problems, structural
+}
+
+// validateMeshbluConfig checks that the meshblu.json can be read
+// from the secret store or the connector Dir and has a device
+func (config *Config) validateMeshbluConfig() error {
+	secretValues := map[string]string{}
+	if config.SecretsMode != "" {
+		store, err := config.GetSecretStore()
+		if err != nil {
+			return err
+		}
+		secretValues, err = store.Read()
+		if err != nil {
+			return err
+		}
+	}
+	meshbluConfig, err := config.readMeshbluConfig(secretValues)
+	if err != nil {
+		return err
+	}
+	return checkMeshbluDevice(meshbluConfig)
+}
+
+// lookExecutable finds the executable, relative paths are resolved
+// against the connector Dir. A bare Command is looked up in the
+// BinPath, the Dir and then the PATH, the default node only in
+// the BinPath
+func (config *Config) lookExecutable(name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return exec.LookPath(name)
+	}
+	if strings.ContainsAny(name, `/\`) {
+		return exec.LookPath(filepath.Join(config.Dir, name))
+	}
+	if config.Command == "" {
+		return exec.LookPath(filepath.Join(config.BinPath, name))
+	}
+	var paths []string
+	if config.BinPath != "" {
+		paths = append(paths, filepath.Join(config.BinPath, name))
+	}
+	if config.Dir != "" {
+		paths = append(paths, filepath.Join(config.Dir, name))
+	}
+	paths = append(paths, name)
+	var err error
+	for _, thePath := range paths {
+		var file string
+		file, err = exec.LookPath(thePath)
+		if err == nil {
+			return file, nil
+		}
+	}
+	return "", err
+}
+
+// checkDir returns an error when the path is not an existing directory
+func checkDir(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("%s does not exist", path)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", path)
+	}
+	return nil
+}
+
+// checkLogFile returns an error when the log file cannot be written
+func checkLogFile(path string) error {
+	info, err := os.Stat(path)
+	if err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("%s is a directory", path)
+		}
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			return fmt.Errorf("%s is not writable", path)
+		}
+		return file.Close()
+	}
+	dir := filepath.Dir(path)
+	if err := checkDir(dir); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(dir, ".validate")
+	if err != nil {
+		return fmt.Errorf("%s is not writable", dir)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
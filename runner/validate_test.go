@@ -0,0 +1,198 @@
+package runner_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kardianos/osext"
+	"github.com/octoblu/go-meshblu-connector-ignition/runner"
+	"github.com/octoblu/go-meshblu-connector-ignition/secrets"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Validate", func() {
+	var dir string
+	var sut *runner.Config
+
+	BeforeEach(func() {
+		sut = newTestConfig("validate")
+		dir = sut.Dir
+		err := ioutil.WriteFile(filepath.Join(dir, "meshblu.json"), []byte(`{"uuid":"some-uuid","token":"some-token"}`), 0600)
+		Expect(err).NotTo(HaveOccurred())
+		command, err := osext.Executable()
+		Expect(err).NotTo(HaveOccurred())
+		sut.ConnectorName = "meshblu-connector-say-hello"
+		sut.GithubSlug = "octoblu/meshblu-connector-say-hello"
+		sut.Stdout = filepath.Join(dir, "log", "connector.log")
+		sut.Command = command
+		Expect(os.Mkdir(filepath.Join(dir, "log"), 0755)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("with a valid config", func() {
+		It("should not return an error", func() {
+			Expect(sut.Validate()).To(Succeed())
+		})
+	})
+
+	Describe("with an empty config", func() {
+		It("should report every required field", func() {
+			err := (&runner.Config{}).Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*runner.ValidationError).Problems).To(Equal([]string{
+				"ServiceName is required",
+				"ConnectorName is required",
+				"GithubSlug is required",
+				"Dir is required, it would default to the current directory",
+				"Stdout is required",
+				"Stderr is required",
+			}))
+		})
+	})
+
+	Describe("with several problems", func() {
+		var problems []string
+
+		BeforeEach(func() {
+			sut.GithubSlug = "meshblu-connector-say-hello"
+			sut.Stdout = filepath.Join(dir, "missing", "connector.log")
+			sut.Command = "not-a-real-command"
+			sut.RestartPolicy = "sometimes"
+			Expect(os.Remove(filepath.Join(dir, "meshblu.json"))).To(Succeed())
+			err := sut.Validate()
+			Expect(err).To(HaveOccurred())
+			problems = err.(*runner.ValidationError).Problems
+		})
+
+		It("should report all of them", func() {
+			Expect(problems).To(HaveLen(5))
+			Expect(problems[0]).To(ContainSubstring("GithubSlug"))
+			Expect(problems[1]).To(ContainSubstring("Stdout"))
+			Expect(problems[2]).To(ContainSubstring("Command not-a-real-command"))
+			Expect(problems[3]).To(ContainSubstring("meshblu.json"))
+			Expect(problems[4]).To(ContainSubstring("RestartPolicy"))
+		})
+	})
+
+	Describe("with only missing files", func() {
+		It("should not be structural", func() {
+			Expect(os.Remove(filepath.Join(dir, "meshblu.json"))).To(Succeed())
+			err := sut.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*runner.ValidationError).Structural).To(BeFalse())
+		})
+	})
+
+	Describe("with an invalid value", func() {
+		It("should be structural", func() {
+			sut.RestartPolicy = "sometimes"
+			err := sut.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*runner.ValidationError).Structural).To(BeTrue())
+		})
+	})
+
+	Describe("with invalid update fields", func() {
+		It("should report all of them", func() {
+			sut.UpdateChannel = "nightly"
+			sut.IgnitionMinVersion = "one"
+			sut.IgnitionMaxVersion = "v2.0.0"
+			err := sut.Validate()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*runner.ValidationError).Problems).To(Equal([]string{
+				"UpdateChannel nightly must be stable, beta or pinned",
+				"IgnitionMinVersion one is not a semver version",
+			}))
+			Expect(err.(*runner.ValidationError).Structural).To(BeTrue())
+		})
+	})
+
+	Describe("with the pinned channel without a PinnedVersion", func() {
+		It("should return an error", func() {
+			sut.UpdateChannel = runner.PinnedChannel
+			Expect(sut.Validate()).To(MatchError(ContainSubstring("UpdateChannel pinned requires a PinnedVersion")))
+		})
+	})
+
+	Describe("with a bare Command in the Dir", func() {
+		It("should find it", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "run-connector"), []byte("#!/bin/sh\n"), 0755)).To(Succeed())
+			sut.BinPath = filepath.Join(dir, "log")
+			sut.Command = "run-connector"
+			Expect(sut.Validate()).To(Succeed())
+		})
+	})
+
+	Describe("with the default Command and a BinPath without node", func() {
+		It("should not look for it in the PATH", func() {
+			sut.BinPath = filepath.Join(dir, "log")
+			sut.Command = ""
+			Expect(sut.Validate()).To(MatchError(ContainSubstring("Command node was not found")))
+		})
+	})
+
+	Describe("with SkipChecksum and a PublicKey", func() {
+		It("should return an error", func() {
+			sut.SkipChecksum = true
+			sut.PublicKey = "c29tZS1rZXk="
+			Expect(sut.Validate()).To(MatchError(ContainSubstring("SkipChecksum cannot be used with a PublicKey")))
+		})
+	})
+
+	Describe("with a meshblu.json without a token", func() {
+		It("should return an error", func() {
+			err := ioutil.WriteFile(filepath.Join(dir, "meshblu.json"), []byte(`{"uuid":"some-uuid"}`), 0600)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sut.Validate()).To(MatchError(ContainSubstring("must have a uuid and token")))
+		})
+	})
+
+	Describe("with a meshblu.json mixing resolveSrv and a hostname", func() {
+		It("should return the go-meshblu error", func() {
+			err := ioutil.WriteFile(filepath.Join(dir, "meshblu.json"), []byte(`{"uuid":"some-uuid","token":"some-token","resolveSrv":true,"hostname":"meshblu.example.com"}`), 0600)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sut.Validate()).To(MatchError(ContainSubstring("hostname property only allowed when 'resolveSrv' is 'false'")))
+		})
+	})
+
+	Describe("with the secret store", func() {
+		var store *secrets.Store
+
+		BeforeEach(func() {
+			Expect(os.Remove(filepath.Join(dir, "meshblu.json"))).To(Succeed())
+			keyFile := filepath.Join(dir, "secrets.key")
+			Expect(ioutil.WriteFile(keyFile, []byte("some-key"), 0600)).To(Succeed())
+			sut.SecretsMode = secrets.ModeFD
+			sut.SecretsKeyFile = keyFile
+			var err error
+			store, err = sut.GetSecretStore()
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Describe("when it has the meshblu.json", func() {
+			It("should read it from the store", func() {
+				Expect(store.Write(map[string]string{"meshblu.json": `{"uuid":"some-uuid","token":"some-token"}`})).To(Succeed())
+				Expect(sut.Validate()).To(Succeed())
+			})
+		})
+
+		Describe("when its meshblu.json is invalid", func() {
+			It("should return the go-meshblu error", func() {
+				Expect(store.Write(map[string]string{"meshblu.json": `{"uuid":"some-uuid","token":"some-token","domain":"example.com"}`})).To(Succeed())
+				Expect(sut.Validate()).To(MatchError(ContainSubstring("domain property only allowed when 'resolveSrv' is 'true'")))
+			})
+		})
+
+		Describe("when it is missing the meshblu.json", func() {
+			It("should return an error", func() {
+				Expect(sut.Validate()).To(MatchError(ContainSubstring("meshblu.json is missing from the secret store")))
+			})
+		})
+	})
+})
@@ -0,0 +1,93 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Delivery modes of the secrets to the connector
+const (
+	ModeFD     = "fd"
+	ModeSocket = "socket"
+)
+
+// FDEnv is set to the file descriptor the connector reads the secrets from
+const FDEnv = "MESHBLU_SECRETS_FD"
+
+// SocketEnv is set to the socket the connector reads the secrets from
+const SocketEnv = "MESHBLU_SECRETS_SOCKET"
+
+// SocketTimeout is how long the one-shot socket waits for the connector
+var SocketTimeout = time.Minute
+
+// PassFD hands the secrets to the command through an inherited pipe,
+// the returned func must be called once the command has started
+func PassFD(cmd *exec.Cmd, secrets map[string]string) (func(), error) {
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, reader)
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%v", FDEnv, 2+len(cmd.ExtraFiles)))
+	go func() {
+		writer.Write(data)
+		writer.Close()
+	}()
+	return func() {
+		reader.Close()
+	}, nil
+}
+
+// ServeSocket hands the secrets to the first connection on a unix socket
+// in a new private directory, removed after the connection or the
+// SocketTimeout. The uid and gid own the directory when not -1
+func ServeSocket(cmd *exec.Cmd, secrets map[string]string, uid, gid int) (func(), error) {
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := ioutil.TempDir("", "meshblu-secrets")
+	if err != nil {
+		return nil, err
+	}
+	if uid != -1 || gid != -1 {
+		err = os.Chown(dir, uid, gid)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+	socketPath := filepath.Join(dir, "secrets.sock")
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", SocketEnv, socketPath))
+
+	timer := time.AfterFunc(SocketTimeout, func() {
+		listener.Close()
+	})
+	go func() {
+		defer os.RemoveAll(dir)
+		defer timer.Stop()
+		conn, err := listener.Accept()
+		listener.Close()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(data)
+	}()
+	return func() {}, nil
+}
@@ -0,0 +1,62 @@
+package secrets_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/secrets"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Deliver", func() {
+	values := map[string]string{"token": "secret-token"}
+
+	Describe("->PassFD", func() {
+		It("should hand the secrets to the command through the fd", func() {
+			cmd := exec.Command("sh", "-c", `cat <&$MESHBLU_SECRETS_FD`)
+			afterStart, err := secrets.PassFD(cmd, values)
+			Expect(err).To(BeNil())
+			Expect(cmd.Env).To(ContainElement("MESHBLU_SECRETS_FD=3"))
+			output, err := cmd.StdoutPipe()
+			Expect(err).To(BeNil())
+			Expect(cmd.Start()).To(Succeed())
+			afterStart()
+			data, _ := ioutil.ReadAll(output)
+			cmd.Wait()
+			received := map[string]string{}
+			Expect(json.Unmarshal(data, &received)).To(Succeed())
+			Expect(received).To(Equal(values))
+		})
+	})
+
+	Describe("->ServeSocket", func() {
+		It("should hand the secrets to the first connection only", func() {
+			cmd := exec.Command("true")
+			_, err := secrets.ServeSocket(cmd, values, -1, -1)
+			Expect(err).To(BeNil())
+			Expect(cmd.Env).To(HaveLen(1))
+			socketPath := strings.TrimPrefix(cmd.Env[0], "MESHBLU_SECRETS_SOCKET=")
+
+			conn, err := net.Dial("unix", socketPath)
+			Expect(err).To(BeNil())
+			data, _ := ioutil.ReadAll(conn)
+			conn.Close()
+			received := map[string]string{}
+			Expect(json.Unmarshal(data, &received)).To(Succeed())
+			Expect(received).To(Equal(values))
+
+			Eventually(func() error {
+				conn, err := net.Dial("unix", socketPath)
+				if err == nil {
+					conn.Close()
+				}
+				return err
+			}).ShouldNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,135 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// DefaultKeyFiles are the machine specific files the key
+// is derived from, the first one that exists is used
+var DefaultKeyFiles = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+// keyLabel separates the secrets key from other uses of the key file
+const keyLabel = "meshblu-connector-ignition/secrets"
+
+// Store defines an encrypted file of secrets
+type Store struct {
+	path string
+	key  []byte
+}
+
+// encryptedFile defines the format of the store on disk
+type encryptedFile struct {
+	Nonce []byte `json:"nonce"`
+	Data  []byte `json:"data"`
+}
+
+// New creates a store for the path, encrypted with the 32 byte key
+func New(path string, key []byte) (*Store, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("the secrets key must be 32 bytes")
+	}
+	return &Store{path, key}, nil
+}
+
+// DeriveKey derives the store key from the contents of the key file,
+// or the first DefaultKeyFiles that exists when it is empty
+func DeriveKey(keyFile string) ([]byte, error) {
+	keyFiles := DefaultKeyFiles
+	if keyFile != "" {
+		keyFiles = []string{keyFile}
+	}
+	for _, keyFile := range keyFiles {
+		data, err := ioutil.ReadFile(keyFile)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(data) == 0 {
+			return nil, fmt.Errorf("the secrets key file %s is empty", keyFile)
+		}
+		mac := hmac.New(sha256.New, data)
+		mac.Write([]byte(keyLabel))
+		return mac.Sum(nil), nil
+	}
+	return nil, fmt.Errorf("no secrets key file found in %v", keyFiles)
+}
+
+// Read decrypts the secrets, returning none when the store does not exist
+func (store *Store) Read() (map[string]string, error) {
+	data, err := ioutil.ReadFile(store.path)
+	if os.IsNotExist(err) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	file := &encryptedFile{}
+	err = json.Unmarshal(data, file)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := store.getCipher()
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := gcm.Open(nil, file.Nonce, file.Data, []byte(filepath.Base(store.path)))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decrypt %s, the key may have changed", store.path)
+	}
+	secrets := map[string]string{}
+	err = json.Unmarshal(plaintext, &secrets)
+	if err != nil {
+		return nil, err
+	}
+	return secrets, nil
+}
+
+// Write encrypts the secrets, replacing the store
+func (store *Store) Write(secrets map[string]string) error {
+	plaintext, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := store.getCipher()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(&encryptedFile{
+		Nonce: nonce,
+		Data:  gcm.Seal(nil, nonce, plaintext, []byte(filepath.Base(store.path))),
+	})
+	if err != nil {
+		return err
+	}
+	tempPath := store.path + ".tmp"
+	err = ioutil.WriteFile(tempPath, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempPath, store.path)
+}
+
+func (store *Store) getCipher() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(store.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
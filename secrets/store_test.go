@@ -0,0 +1,79 @@
+package secrets_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/secrets"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Store", func() {
+	var dir, keyFile, storePath string
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "secrets-test")
+		keyFile = filepath.Join(dir, "machine-id")
+		storePath = filepath.Join(dir, "secrets.enc")
+		ioutil.WriteFile(keyFile, []byte("0123456789abcdef\n"), 0644)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("->DeriveKey", func() {
+		It("should derive a 32 byte key from the key file", func() {
+			key, err := secrets.DeriveKey(keyFile)
+			Expect(err).To(BeNil())
+			Expect(key).To(HaveLen(32))
+		})
+
+		It("should return an error when the key file is missing", func() {
+			_, err := secrets.DeriveKey(filepath.Join(dir, "missing"))
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Describe("->Write", func() {
+		var store *secrets.Store
+
+		BeforeEach(func() {
+			key, _ := secrets.DeriveKey(keyFile)
+			store, _ = secrets.New(storePath, key)
+		})
+
+		It("should read nothing before the store exists", func() {
+			values, err := store.Read()
+			Expect(err).To(BeNil())
+			Expect(values).To(BeEmpty())
+		})
+
+		It("should read the secrets back", func() {
+			Expect(store.Write(map[string]string{"meshblu.json": `{"token":"secret-token"}`})).To(Succeed())
+			values, err := store.Read()
+			Expect(err).To(BeNil())
+			Expect(values).To(Equal(map[string]string{"meshblu.json": `{"token":"secret-token"}`}))
+		})
+
+		It("should not write the secrets in plaintext", func() {
+			store.Write(map[string]string{"token": "secret-token"})
+			data, _ := ioutil.ReadFile(storePath)
+			Expect(string(data)).NotTo(ContainSubstring("secret-token"))
+			info, _ := os.Stat(storePath)
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0600)))
+		})
+
+		It("should not decrypt with another key", func() {
+			store.Write(map[string]string{"token": "secret-token"})
+			ioutil.WriteFile(keyFile, []byte("another-machine\n"), 0644)
+			key, _ := secrets.DeriveKey(keyFile)
+			otherStore, _ := secrets.New(storePath, key)
+			_, err := otherStore.Read()
+			Expect(err).NotTo(BeNil())
+		})
+	})
+})
@@ -0,0 +1,73 @@
+package source
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Vars defines the values substituted into a source template
+type Vars map[string]string
+
+// Expand replaces the {key} placeholders in the template with the vars.
+// A template without placeholders is treated as a base url or directory
+// and the suffix is appended to it
+func Expand(template, suffix string, vars Vars) string {
+	if !strings.Contains(template, "{") {
+		template = strings.TrimRight(template, "/\\") + suffix
+	}
+	pairs := []string{}
+	for key, value := range vars {
+		pairs = append(pairs, fmt.Sprintf("{%s}", key), value)
+	}
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// Open returns the body of an http(s) url, a file:// url or a local path
+func Open(uri string) (io.ReadCloser, error) {
+	if IsHTTP(uri) {
+		return openHTTP(uri)
+	}
+	path, err := getLocalPath(uri)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// IsHTTP returns true if the uri is an http or https url
+func IsHTTP(uri string) bool {
+	return strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://")
+}
+
+func openHTTP(uri string) (io.ReadCloser, error) {
+	res, err := http.Get(uri)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != 200 {
+		res.Body.Close()
+		return nil, fmt.Errorf("Unexpected response status code %v", res.StatusCode)
+	}
+	return res.Body, nil
+}
+
+func getLocalPath(uri string) (string, error) {
+	if !strings.HasPrefix(uri, "file://") {
+		return filepath.FromSlash(uri), nil
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	path := parsed.Path
+	if len(path) > 2 && path[0] == '/' && path[2] == ':' {
+		// file:///C:/path on windows
+		path = path[1:]
+	}
+	return filepath.FromSlash(path), nil
+}
@@ -0,0 +1,96 @@
+package source_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/source"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Source", func() {
+	vars := source.Vars{"tag": "v1.0.0", "file": "test.tar.gz"}
+
+	Describe("->Expand", func() {
+		It("should replace the placeholders in a template", func() {
+			uri := source.Expand("https://mirror.local/{tag}/files/{file}", "/{tag}/{file}", vars)
+			Expect(uri).To(Equal("https://mirror.local/v1.0.0/files/test.tar.gz"))
+		})
+
+		It("should append the suffix to a base url", func() {
+			uri := source.Expand("https://mirror.local/releases/", "/{tag}/{file}", vars)
+			Expect(uri).To(Equal("https://mirror.local/releases/v1.0.0/test.tar.gz"))
+		})
+
+		It("should append the suffix to a directory", func() {
+			uri := source.Expand("/opt/mirror", "/{tag}/{file}", vars)
+			Expect(uri).To(Equal("/opt/mirror/v1.0.0/test.tar.gz"))
+		})
+	})
+
+	Describe("->Open", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "source-test")
+			ioutil.WriteFile(filepath.Join(dir, "test.txt"), []byte("hello"), 0644)
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should open a local path", func() {
+			body, err := source.Open(filepath.Join(dir, "test.txt"))
+			Expect(err).To(BeNil())
+			data, _ := ioutil.ReadAll(body)
+			body.Close()
+			Expect(string(data)).To(Equal("hello"))
+		})
+
+		It("should open a file url", func() {
+			body, err := source.Open("file://" + filepath.ToSlash(filepath.Join(dir, "test.txt")))
+			Expect(err).To(BeNil())
+			data, _ := ioutil.ReadAll(body)
+			body.Close()
+			Expect(string(data)).To(Equal("hello"))
+		})
+
+		Describe("with an http url", func() {
+			var server *httptest.Server
+
+			BeforeEach(func() {
+				server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Path != "/test.txt" {
+						w.WriteHeader(404)
+						return
+					}
+					fmt.Fprint(w, "hello")
+				}))
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("should return the body", func() {
+				body, err := source.Open(server.URL + "/test.txt")
+				Expect(err).To(BeNil())
+				data, _ := ioutil.ReadAll(body)
+				body.Close()
+				Expect(string(data)).To(Equal("hello"))
+			})
+
+			It("should return an error on a bad status code", func() {
+				_, err := source.Open(server.URL + "/missing.txt")
+				Expect(err).NotTo(BeNil())
+			})
+		})
+	})
+})
@@ -1,9 +1,6 @@
 package status
 
 import (
-	"bytes"
-	"strings"
-
 	"github.com/octoblu/go-meshblu/http/meshblu"
 )
 
@@ -17,7 +14,10 @@ type Client struct {
 // Status defines the device management interface
 type Status interface {
 	Fetch() error
-	UpdateErrors(data []byte) error
+	UpdateErrorEntries(entries []ErrorEntry) error
+	UpdateStopped(stopped bool) error
+	UpdateFailed(tag string, updateErr error) error
+	UpdateIgnition(ignition *Ignition) error
 	ResetErrors() error
 }
 
@@ -47,12 +47,12 @@ func (client *Client) Fetch() error {
 	return nil
 }
 
-// ResetErrors removes the error array from the device
+// ResetErrors removes the errors and their details from the device
 func (client *Client) ResetErrors() error {
 	if client.uuid == "" {
 		return nil
 	}
-	body, err := NewUpdateErrorsBody([]string{})
+	body, err := NewUpdateErrorEntriesBody([]ErrorEntry{})
 	if err != nil {
 		return err
 	}
@@ -60,17 +60,51 @@ func (client *Client) ResetErrors() error {
 	return err
 }
 
-// UpdateErrors updates the status device with the errors
-func (client *Client) UpdateErrors(data []byte) error {
+// UpdateStopped updates the status device with the stopped state of the connector
+func (client *Client) UpdateStopped(stopped bool) error {
 	if client.uuid == "" {
 		return nil
 	}
-	if data == nil {
+	body, err := NewUpdateStoppedBody(stopped)
+	if err != nil {
+		return err
+	}
+	_, err = client.meshbluClient.UpdateDevice(client.uuid, body)
+	return err
+}
+
+// UpdateFailed updates the status device with the reason the connector update failed
+func (client *Client) UpdateFailed(tag string, updateErr error) error {
+	if client.uuid == "" {
+		return nil
+	}
+	body, err := NewUpdateFailedBody(tag, updateErr)
+	if err != nil {
+		return err
+	}
+	_, err = client.meshbluClient.UpdateDevice(client.uuid, body)
+	return err
+}
+
+// UpdateErrorEntries updates the status device with the deduplicated errors
+func (client *Client) UpdateErrorEntries(entries []ErrorEntry) error {
+	if client.uuid == "" {
+		return nil
+	}
+	body, err := NewUpdateErrorEntriesBody(entries)
+	if err != nil {
+		return err
+	}
+	_, err = client.meshbluClient.UpdateDevice(client.uuid, body)
+	return err
+}
+
+// UpdateIgnition updates the ignition section of the status device
+func (client *Client) UpdateIgnition(ignition *Ignition) error {
+	if client.uuid == "" {
 		return nil
 	}
-	buf := bytes.NewBuffer(data)
-	errors := strings.Split(buf.String(), "\n")
-	body, err := NewUpdateErrorsBody(errors)
+	body, err := NewUpdateIgnitionBody(ignition)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,86 @@
+package status
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// States of the connector reported in the ignition section
+const (
+	StateStarting   = "starting"
+	StateRunning    = "running"
+	StateBackingOff = "backing-off"
+	StateUpdating   = "updating"
+	StateStopped    = "stopped"
+	StateCrashed    = "crashed"
+	StateExited     = "exited"
+	StateFailed     = "failed"
+)
+
+// Ignition defines the ignition section of the status device
+type Ignition struct {
+	State            string        `json:"state"`
+	PID              int           `json:"pid,omitempty"`
+	ConnectorVersion string        `json:"connectorVersion"`
+	IgnitionVersion  string        `json:"ignitionVersion"`
+	StartedAt        int64         `json:"startedAt,omitempty"`
+	RestartCount     int           `json:"restartCount"`
+	LastExit         *Exit         `json:"lastExit,omitempty"`
+	LastUpdate       *UpdateResult `json:"lastUpdate,omitempty"`
+	NextBackoff      int64         `json:"nextBackoff,omitempty"`
+	FailedReason     string        `json:"failedReason,omitempty"`
+	LastUnhealthy    *Unhealthy    `json:"lastUnhealthy,omitempty"`
+	UpdatedAt        int64         `json:"updatedAt"`
+}
+
+// Exit defines how the connector process last exited
+type Exit struct {
+	Code      int    `json:"code"`
+	Signal    string `json:"signal,omitempty"`
+	Reason    string `json:"reason"`
+	Unhealthy bool   `json:"unhealthy,omitempty"`
+	At        int64  `json:"at"`
+}
+
+// Unhealthy defines the health check that last restarted the connector
+type Unhealthy struct {
+	Check string `json:"check"`
+	Error string `json:"error"`
+	At    int64  `json:"at"`
+}
+
+// Failed returns true unless the connector exited with code 0,
+// an exit after it was terminated as unhealthy always failed
+func (exit *Exit) Failed() bool {
+	return exit.Unhealthy || exit.Code != 0 || exit.Signal != ""
+}
+
+// UpdateResult defines the result of the last connector update
+type UpdateResult struct {
+	Tag    string `json:"tag"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	At     int64  `json:"at"`
+}
+
+// UpdateDeviceIgnition defines the update properties
+type UpdateDeviceIgnition struct {
+	Ignition *Ignition `json:"ignition"`
+}
+
+// NewUpdateIgnitionBody returns the json body for updating the device
+func NewUpdateIgnitionBody(ignition *Ignition) (io.Reader, error) {
+	ignition.UpdatedAt = Now()
+	data, err := json.Marshal(&UpdateDeviceIgnition{Ignition: ignition})
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// Now returns the current time in milliseconds, like the other status timestamps
+func Now() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
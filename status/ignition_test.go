@@ -0,0 +1,60 @@
+package status_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ignition", func() {
+	Describe("Exit.Failed", func() {
+		It("should be false when it exited with code 0", func() {
+			Expect((&status.Exit{Code: 0}).Failed()).To(BeFalse())
+		})
+
+		It("should be true when it exited with another code", func() {
+			Expect((&status.Exit{Code: 1}).Failed()).To(BeTrue())
+		})
+
+		It("should be true when it was killed by a signal", func() {
+			Expect((&status.Exit{Code: 0, Signal: "SIGKILL"}).Failed()).To(BeTrue())
+		})
+	})
+
+	Describe("NewUpdateIgnitionBody", func() {
+		var body map[string]map[string]interface{}
+
+		BeforeEach(func() {
+			reader, err := status.NewUpdateIgnitionBody(&status.Ignition{
+				State:            status.StateCrashed,
+				ConnectorVersion: "1.0.0",
+				LastExit:         &status.Exit{Code: 1, Reason: "exited with code 1"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			data, err := ioutil.ReadAll(reader)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(json.Unmarshal(data, &body)).To(Succeed())
+		})
+
+		It("should update the ignition section", func() {
+			Expect(body).To(HaveKey("ignition"))
+			Expect(body["ignition"]["state"]).To(Equal("crashed"))
+			Expect(body["ignition"]["connectorVersion"]).To(Equal("1.0.0"))
+			Expect(body["ignition"]["lastExit"]).To(HaveKeyWithValue("code", BeNumerically("==", 1)))
+		})
+
+		It("should set the updatedAt", func() {
+			Expect(body["ignition"]["updatedAt"]).To(BeNumerically(">", 0))
+		})
+
+		It("should leave out the empty fields", func() {
+			Expect(body["ignition"]).NotTo(HaveKey("pid"))
+			Expect(body["ignition"]).NotTo(HaveKey("failedReason"))
+			Expect(body["ignition"]).NotTo(HaveKey("lastUpdate"))
+		})
+	})
+})
@@ -17,6 +17,26 @@ type UpdateDeviceErrors struct {
 	Errors         []string `json:"errors"`
 }
 
+// UpdateDeviceErrorEntries defines the update properties
+type UpdateDeviceErrorEntries struct {
+	UpdateErrorsAt int64        `json:"updateErrorsAt"`
+	Errors         []string     `json:"errors"`
+	ErrorDetails   []ErrorEntry `json:"errorDetails"`
+}
+
+// UpdateDeviceStopped defines the update properties
+type UpdateDeviceStopped struct {
+	UpdateStoppedAt int64 `json:"updateStoppedAt"`
+	Stopped         bool  `json:"stopped"`
+}
+
+// UpdateDeviceUpdateFailed defines the update properties
+type UpdateDeviceUpdateFailed struct {
+	UpdateFailedAt int64  `json:"updateFailedAt"`
+	UpdateTag      string `json:"updateTag"`
+	UpdateError    string `json:"updateError"`
+}
+
 // ParseMeshbluDevice creates a device from a JSON byte array
 func ParseMeshbluDevice(data []byte) (*MeshbluDevice, error) {
 	device := &MeshbluDevice{}
@@ -36,3 +56,49 @@ func NewUpdateErrorsBody(errors []string) (io.Reader, error) {
 	}
 	return bytes.NewReader(data), nil
 }
+
+// NewUpdateErrorEntriesBody returns the json body for updating the device,
+// errors keeps the plain messages for existing consumers
+func NewUpdateErrorEntriesBody(entries []ErrorEntry) (io.Reader, error) {
+	errors := make([]string, len(entries))
+	for i, entry := range entries {
+		errors[i] = entry.Message
+	}
+	updateDeviceErrorEntries := &UpdateDeviceErrorEntries{
+		Errors:         errors,
+		ErrorDetails:   entries,
+		UpdateErrorsAt: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	data, err := json.Marshal(updateDeviceErrorEntries)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// NewUpdateStoppedBody returns the json body for updating the device
+func NewUpdateStoppedBody(stopped bool) (io.Reader, error) {
+	updateDeviceStopped := &UpdateDeviceStopped{
+		Stopped:         stopped,
+		UpdateStoppedAt: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	data, err := json.Marshal(updateDeviceStopped)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// NewUpdateFailedBody returns the json body for updating the device
+func NewUpdateFailedBody(tag string, updateErr error) (io.Reader, error) {
+	updateDeviceUpdateFailed := &UpdateDeviceUpdateFailed{
+		UpdateTag:      tag,
+		UpdateError:    updateErr.Error(),
+		UpdateFailedAt: time.Now().UnixNano() / int64(time.Millisecond),
+	}
+	data, err := json.Marshal(updateDeviceUpdateFailed)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
@@ -0,0 +1,252 @@
+package status
+
+import (
+	"bytes"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+)
+
+var mainLogger logger.MainLogger
+
+// ErrorEntry defines a deduplicated line of error output
+type ErrorEntry struct {
+	Message     string `json:"message"`
+	Count       int    `json:"count"`
+	FirstSeenAt int64  `json:"firstSeenAt"`
+	LastSeenAt  int64  `json:"lastSeenAt"`
+	sequence    int64
+}
+
+// ReporterOptions defines how often and how much is sent to the status device
+type ReporterOptions struct {
+	// Debounce is how long to wait for more lines before sending, defaults to 2s
+	Debounce time.Duration
+
+	// MinInterval is the minimum time between updates, defaults to 10s
+	MinInterval time.Duration
+
+	// MaxEntries is the number of unique lines kept, defaults to 20
+	MaxEntries int
+
+	// MaxMessageLength truncates long lines, defaults to 512
+	MaxMessageLength int
+}
+
+// ErrorReporter streams error output to the status device
+type ErrorReporter interface {
+	io.Writer
+
+	// Reset forgets the lines reported so far
+	// and clears them from the status device
+	Reset()
+
+	// Close sends the pending lines and stops the reporter,
+	// it returns once they are sent
+	Close() error
+}
+
+type errorReporter struct {
+	mutex     sync.Mutex
+	status    Status
+	options   ReporterOptions
+	partial   []byte
+	entries   map[string]*ErrorEntry
+	sequence  int64
+	reported  bool
+	cleared   bool
+	dirty     chan bool
+	done      chan bool
+	finished  chan bool
+	closeOnce sync.Once
+	lastFlush time.Time
+}
+
+// NewErrorReporter creates a reporter sending batches of error lines to the status device
+func NewErrorReporter(status Status, options ReporterOptions) ErrorReporter {
+	if mainLogger == nil {
+		mainLogger = logger.GetMainLogger()
+	}
+	if options.Debounce <= 0 {
+		options.Debounce = 2 * time.Second
+	}
+	if options.MinInterval <= 0 {
+		options.MinInterval = 10 * time.Second
+	}
+	if options.MaxEntries <= 0 {
+		options.MaxEntries = 20
+	}
+	if options.MaxMessageLength <= 0 {
+		options.MaxMessageLength = 512
+	}
+	reporter := &errorReporter{
+		status:  status,
+		options: options,
+		entries: map[string]*ErrorEntry{},
+		dirty:    make(chan bool, 1),
+		done:     make(chan bool),
+		finished: make(chan bool),
+	}
+	go reporter.run()
+	return reporter
+}
+
+// Write collects the complete lines written to the reporter
+func (reporter *errorReporter) Write(p []byte) (int, error) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	reporter.partial = append(reporter.partial, p...)
+	added := false
+	for {
+		newline := bytes.IndexByte(reporter.partial, '\n')
+		if newline < 0 {
+			break
+		}
+		line := string(reporter.partial[:newline])
+		reporter.partial = reporter.partial[newline+1:]
+		if reporter.add(line) {
+			added = true
+		}
+	}
+	if len(reporter.partial) > reporter.options.MaxMessageLength {
+		if reporter.add(string(reporter.partial)) {
+			added = true
+		}
+		reporter.partial = nil
+	}
+	if added {
+		reporter.markDirty()
+	}
+	return len(p), nil
+}
+
+// Reset forgets the lines reported so far, the status
+// device is cleared when they were already sent
+func (reporter *errorReporter) Reset() {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	reporter.partial = nil
+	reporter.entries = map[string]*ErrorEntry{}
+	if reporter.reported {
+		reporter.cleared = true
+		reporter.markDirty()
+	}
+}
+
+// Close sends the pending lines and stops the reporter,
+// it returns once they are sent
+func (reporter *errorReporter) Close() error {
+	reporter.closeOnce.Do(func() {
+		close(reporter.done)
+	})
+	<-reporter.finished
+	return nil
+}
+
+func (reporter *errorReporter) markDirty() {
+	select {
+	case reporter.dirty <- true:
+	default:
+	}
+}
+
+func (reporter *errorReporter) add(line string) bool {
+	message := strings.TrimSpace(line)
+	if message == "" {
+		return false
+	}
+	if len(message) > reporter.options.MaxMessageLength {
+		message = message[:reporter.options.MaxMessageLength]
+	}
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	entry, ok := reporter.entries[message]
+	if !ok {
+		if len(reporter.entries) >= reporter.options.MaxEntries {
+			reporter.evictOldest()
+		}
+		entry = &ErrorEntry{Message: message, FirstSeenAt: now}
+		reporter.entries[message] = entry
+	}
+	reporter.sequence++
+	entry.Count++
+	entry.LastSeenAt = now
+	entry.sequence = reporter.sequence
+	return true
+}
+
+func (reporter *errorReporter) evictOldest() {
+	var oldest *ErrorEntry
+	for _, entry := range reporter.entries {
+		if oldest == nil || entry.sequence < oldest.sequence {
+			oldest = entry
+		}
+	}
+	if oldest != nil {
+		delete(reporter.entries, oldest.Message)
+	}
+}
+
+func (reporter *errorReporter) run() {
+	defer close(reporter.finished)
+	for {
+		select {
+		case <-reporter.done:
+			select {
+			case <-reporter.dirty:
+				reporter.flush()
+			default:
+			}
+			return
+		case <-reporter.dirty:
+		}
+		wait := reporter.options.Debounce
+		if sinceFlush := time.Since(reporter.lastFlush); sinceFlush+wait < reporter.options.MinInterval {
+			wait = reporter.options.MinInterval - sinceFlush
+		}
+		select {
+		case <-reporter.done:
+			reporter.flush()
+			return
+		case <-time.After(wait):
+		}
+		reporter.flush()
+	}
+}
+
+func (reporter *errorReporter) flush() {
+	entries, cleared := reporter.snapshot()
+	reporter.lastFlush = time.Now()
+	if len(entries) == 0 && !cleared {
+		return
+	}
+	err := reporter.status.UpdateErrorEntries(entries)
+	if err != nil {
+		mainLogger.Error("status.reporter", "Error updating status device with errors", err)
+	}
+}
+
+// snapshot returns the entries to send and whether
+// the status device has to be cleared without them
+func (reporter *errorReporter) snapshot() ([]ErrorEntry, bool) {
+	reporter.mutex.Lock()
+	defer reporter.mutex.Unlock()
+	entries := make([]ErrorEntry, 0, len(reporter.entries))
+	for _, entry := range reporter.entries {
+		entries = append(entries, *entry)
+	}
+	sort.Sort(byLastSeen(entries))
+	cleared := reporter.cleared
+	reporter.cleared = false
+	reporter.reported = len(entries) > 0
+	return entries, cleared
+}
+
+type byLastSeen []ErrorEntry
+
+func (entries byLastSeen) Len() int           { return len(entries) }
+func (entries byLastSeen) Swap(i, j int)      { entries[i], entries[j] = entries[j], entries[i] }
+func (entries byLastSeen) Less(i, j int) bool { return entries[i].sequence < entries[j].sequence }
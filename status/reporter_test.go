@@ -0,0 +1,137 @@
+package status_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/status"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type fakeStatus struct {
+	status.Status
+	mutex   sync.Mutex
+	updates [][]status.ErrorEntry
+}
+
+func (fake *fakeStatus) UpdateErrorEntries(entries []status.ErrorEntry) error {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	fake.updates = append(fake.updates, entries)
+	return nil
+}
+
+func (fake *fakeStatus) getUpdates() [][]status.ErrorEntry {
+	fake.mutex.Lock()
+	defer fake.mutex.Unlock()
+	return fake.updates
+}
+
+var _ = Describe("ErrorReporter", func() {
+	var fake *fakeStatus
+	var sut status.ErrorReporter
+
+	BeforeEach(func() {
+		fake = &fakeStatus{}
+		sut = status.NewErrorReporter(fake, status.ReporterOptions{
+			Debounce:    10 * time.Millisecond,
+			MinInterval: 200 * time.Millisecond,
+			MaxEntries:  3,
+		})
+	})
+
+	AfterEach(func() {
+		sut.Close()
+	})
+
+	Describe("when the same line is written many times", func() {
+		BeforeEach(func() {
+			for i := 0; i < 5; i++ {
+				fmt.Fprintln(sut, "Error: oh no")
+			}
+		})
+
+		It("should send a single entry with the count", func() {
+			Eventually(fake.getUpdates).Should(HaveLen(1))
+			entries := fake.getUpdates()[0]
+			Expect(entries).To(HaveLen(1))
+			Expect(entries[0].Message).To(Equal("Error: oh no"))
+			Expect(entries[0].Count).To(Equal(5))
+			Expect(entries[0].FirstSeenAt).To(BeNumerically("<=", entries[0].LastSeenAt))
+		})
+	})
+
+	Describe("when a line is written in parts", func() {
+		BeforeEach(func() {
+			fmt.Fprint(sut, "Error: ")
+			fmt.Fprint(sut, "split\n")
+		})
+
+		It("should join the parts", func() {
+			Eventually(fake.getUpdates).Should(HaveLen(1))
+			Expect(fake.getUpdates()[0][0].Message).To(Equal("Error: split"))
+		})
+	})
+
+	Describe("when there are more lines than the MaxEntries", func() {
+		BeforeEach(func() {
+			lines := []string{}
+			for i := 0; i < 5; i++ {
+				lines = append(lines, fmt.Sprintf("error %v", i))
+			}
+			fmt.Fprintln(sut, strings.Join(lines, "\n"))
+		})
+
+		It("should keep the most recent lines", func() {
+			Eventually(fake.getUpdates).Should(HaveLen(1))
+			entries := fake.getUpdates()[0]
+			Expect(entries).To(HaveLen(3))
+			Expect(entries[0].Message).To(Equal("error 2"))
+			Expect(entries[2].Message).To(Equal("error 4"))
+		})
+	})
+
+	Describe("->Reset", func() {
+		Describe("when the lines were sent", func() {
+			It("should clear them from the status device", func() {
+				fmt.Fprintln(sut, "Error: oh no")
+				Eventually(fake.getUpdates).Should(HaveLen(1))
+				sut.Reset()
+				Eventually(fake.getUpdates, time.Second).Should(HaveLen(2))
+				Expect(fake.getUpdates()[1]).To(BeEmpty())
+			})
+		})
+
+		Describe("when nothing was sent", func() {
+			It("should not update the status device", func() {
+				sut.Reset()
+				Consistently(fake.getUpdates, 300*time.Millisecond).Should(BeEmpty())
+			})
+		})
+	})
+
+	Describe("->Close", func() {
+		It("should return once the pending lines are sent", func() {
+			fmt.Fprintln(sut, "Error: last words")
+			Expect(sut.Close()).To(Succeed())
+			Expect(fake.getUpdates()).To(HaveLen(1))
+		})
+	})
+
+	Describe("when lines keep coming", func() {
+		BeforeEach(func() {
+			for i := 0; i < 10; i++ {
+				fmt.Fprintln(sut, "chatty")
+				time.Sleep(20 * time.Millisecond)
+			}
+		})
+
+		It("should rate limit the updates", func() {
+			Expect(len(fake.getUpdates())).To(BeNumerically("<=", 2))
+		})
+	})
+})
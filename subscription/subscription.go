@@ -0,0 +1,125 @@
+package subscription
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Stream defines the interface to listen for device changes
+type Stream interface {
+	Listen(onConnect, onChange func()) error
+	Close() error
+}
+
+// Event defines a message received on the device stream
+type Event struct {
+	Type string `json:"type"`
+}
+
+// Client defines the structure of the device stream
+type Client struct {
+	uri, uuid, token string
+	httpClient       *http.Client
+	response         *http.Response
+	closed           bool
+	mutex            sync.Mutex
+}
+
+// New creates a device stream for the meshblu server uri
+func New(uri, uuid, token string) Stream {
+	return &Client{
+		uri:        strings.TrimRight(uri, "/"),
+		uuid:       uuid,
+		token:      token,
+		httpClient: &http.Client{},
+	}
+}
+
+// Listen connects to the device stream, calling onConnect once
+// connected and onChange for every config change. It returns
+// when the stream ends, with a nil error when it was closed
+func (client *Client) Listen(onConnect, onChange func()) error {
+	request, err := http.NewRequest("GET", fmt.Sprintf("%s/subscribe/%s", client.uri, client.uuid), nil)
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(client.uuid, client.token)
+	request.Header.Add("Accept", "application/json")
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("Meshblu returned invalid stream response code: %v", response.StatusCode)
+	}
+
+	if !client.setResponse(response) {
+		return nil
+	}
+	onConnect()
+
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if IsConfigEvent([]byte(line)) {
+			onChange()
+		}
+	}
+	if client.isClosed() {
+		return nil
+	}
+	err = scanner.Err()
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("device stream ended")
+}
+
+// Close disconnects from the device stream and stops listening
+func (client *Client) Close() error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	client.closed = true
+	if client.response == nil {
+		return nil
+	}
+	return client.response.Body.Close()
+}
+
+// IsConfigEvent returns true if the stream message is a device
+// config change, untyped messages are ignored
+func IsConfigEvent(data []byte) bool {
+	event := &Event{}
+	err := json.Unmarshal(data, event)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(event.Type, "config")
+}
+
+func (client *Client) setResponse(response *http.Response) bool {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	if client.closed {
+		return false
+	}
+	client.response = response
+	return true
+}
+
+func (client *Client) isClosed() bool {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+	return client.closed
+}
@@ -0,0 +1,123 @@
+package subscription_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/subscription"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Subscription", func() {
+	Describe("->IsConfigEvent", func() {
+		It("should accept config events", func() {
+			Expect(subscription.IsConfigEvent([]byte(`{"type":"config"}`))).To(BeTrue())
+			Expect(subscription.IsConfigEvent([]byte(`{"type":"configure.received"}`))).To(BeTrue())
+		})
+
+		It("should ignore untyped events", func() {
+			Expect(subscription.IsConfigEvent([]byte(`{"uuid":"device-uuid"}`))).To(BeFalse())
+			Expect(subscription.IsConfigEvent([]byte(`{}`))).To(BeFalse())
+		})
+
+		It("should ignore other events", func() {
+			Expect(subscription.IsConfigEvent([]byte(`{"type":"message.received"}`))).To(BeFalse())
+			Expect(subscription.IsConfigEvent([]byte(`not-json`))).To(BeFalse())
+		})
+	})
+
+	Describe("->Listen", func() {
+		var server *httptest.Server
+		var events chan string
+		var requests chan *http.Request
+
+		BeforeEach(func() {
+			events = make(chan string, 10)
+			requests = make(chan *http.Request, 1)
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/subscribe/device-uuid" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				requests <- r
+				w.WriteHeader(http.StatusOK)
+				w.(http.Flusher).Flush()
+				for {
+					select {
+					case event, ok := <-events:
+						if !ok {
+							return
+						}
+						fmt.Fprintln(w, event)
+						w.(http.Flusher).Flush()
+					case <-r.Context().Done():
+						return
+					}
+				}
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should authenticate as the device", func() {
+			stream := subscription.New(server.URL, "device-uuid", "device-token")
+			go stream.Listen(func() {}, func() {})
+			defer stream.Close()
+
+			var request *http.Request
+			Eventually(requests).Should(Receive(&request))
+			uuid, token, ok := request.BasicAuth()
+			Expect(ok).To(BeTrue())
+			Expect(uuid).To(Equal("device-uuid"))
+			Expect(token).To(Equal("device-token"))
+		})
+
+		It("should call onChange for config events only", func() {
+			connected := make(chan bool, 1)
+			changes := make(chan bool, 10)
+			stream := subscription.New(server.URL, "device-uuid", "device-token")
+			go stream.Listen(func() { connected <- true }, func() { changes <- true })
+			defer stream.Close()
+
+			Eventually(connected).Should(Receive())
+			events <- `{"type":"message.received"}`
+			events <- `{"uuid":"device-uuid"}`
+			events <- ""
+			events <- `{"type":"configure.received"}`
+			Eventually(changes).Should(Receive())
+			Consistently(changes, "100ms").ShouldNot(Receive())
+		})
+
+		It("should return an error when the stream ends", func() {
+			stream := subscription.New(server.URL, "device-uuid", "device-token")
+			close(events)
+			err := stream.Listen(func() {}, func() {})
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should return an error when the stream is unavailable", func() {
+			stream := subscription.New(server.URL, "other-uuid", "device-token")
+			err := stream.Listen(func() {}, func() {})
+			Expect(err).To(MatchError("Meshblu returned invalid stream response code: 404"))
+		})
+
+		It("should return nil when closed", func() {
+			connected := make(chan bool, 1)
+			done := make(chan error, 1)
+			stream := subscription.New(server.URL, "device-uuid", "device-token")
+			go func() {
+				done <- stream.Listen(func() { connected <- true }, func() {})
+			}()
+			Eventually(connected).Should(Receive())
+			stream.Close()
+			var err error
+			Eventually(done).Should(Receive(&err))
+			Expect(err).To(BeNil())
+		})
+	})
+})
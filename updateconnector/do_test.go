@@ -0,0 +1,120 @@
+package updateconnector_test
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+	"github.com/octoblu/go-meshblu-connector-ignition/updateconnector"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+func writeRelease(mirror, tag, content string, checksum bool) {
+	releaseDir := filepath.Join(mirror, tag)
+	os.MkdirAll(releaseDir, 0755)
+	fileName := fmt.Sprintf("test-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH)
+	file, _ := os.Create(filepath.Join(releaseDir, fileName))
+	gzipWriter := gzip.NewWriter(file)
+	tarWriter := tar.NewWriter(gzipWriter)
+	tarWriter.WriteHeader(&tar.Header{Name: "index.js", Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	tarWriter.Write([]byte(content))
+	tarWriter.Close()
+	gzipWriter.Close()
+	file.Close()
+	if !checksum {
+		return
+	}
+	data, _ := ioutil.ReadFile(filepath.Join(releaseDir, fileName))
+	sum := sha256.Sum256(data)
+	manifest := fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), fileName)
+	ioutil.WriteFile(filepath.Join(releaseDir, updateconnector.ChecksumsFileName), []byte(manifest), 0644)
+}
+
+var _ = Describe("UpdateConnector.Do", func() {
+	var sut updateconnector.UpdateConnector
+	var mirror, dir string
+
+	BeforeEach(func() {
+		mirror, _ = ioutil.TempDir("", "updateconnector-mirror")
+		dir, _ = ioutil.TempDir("", "updateconnector-dir")
+		ioutil.WriteFile(filepath.Join(dir, "index.js"), []byte("v1"), 0644)
+		ioutil.WriteFile(filepath.Join(dir, "meshblu.json"), []byte("{}"), 0644)
+		writeRelease(mirror, "v2.0.0", "v2", true)
+		writeRelease(mirror, "v3.0.0", "v3", false)
+		// the extractor writes to the disk, so the swap has to as well
+		sut, _ = updateconnector.New("testblu/test", "test", dir, mirror, updateconnector.VerifyOptions{}, afero.NewOsFs(), logger.NewFakeMainLogger())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(mirror)
+		os.RemoveAll(dir)
+	})
+
+	Describe("with a verified release", func() {
+		var err error
+
+		BeforeEach(func() {
+			err = sut.Do("v2.0.0")
+		})
+
+		It("should not have error", func() {
+			Expect(err).To(BeNil())
+		})
+
+		It("should swap in the new version", func() {
+			data, _ := ioutil.ReadFile(filepath.Join(dir, "index.js"))
+			Expect(string(data)).To(Equal("v2"))
+		})
+
+		It("should leave the other files alone", func() {
+			_, statErr := os.Stat(filepath.Join(dir, "meshblu.json"))
+			Expect(statErr).To(BeNil())
+		})
+
+		Describe("->Rollback", func() {
+			BeforeEach(func() {
+				err = sut.Rollback()
+			})
+
+			It("should not have error", func() {
+				Expect(err).To(BeNil())
+			})
+
+			It("should restore the previous version", func() {
+				data, _ := ioutil.ReadFile(filepath.Join(dir, "index.js"))
+				Expect(string(data)).To(Equal("v1"))
+			})
+
+			It("should not roll back twice", func() {
+				Expect(sut.Rollback()).NotTo(BeNil())
+			})
+		})
+	})
+
+	Describe("with a release missing the checksum manifest", func() {
+		var err error
+
+		BeforeEach(func() {
+			err = sut.Do("v3.0.0")
+		})
+
+		It("should refuse the update", func() {
+			Expect(updateconnector.IsVerificationError(err)).To(BeTrue())
+		})
+
+		It("should leave the live version alone", func() {
+			data, _ := ioutil.ReadFile(filepath.Join(dir, "index.js"))
+			Expect(string(data)).To(Equal("v1"))
+		})
+	})
+})
@@ -0,0 +1,39 @@
+package updateconnector
+
+import (
+	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+	"github.com/spf13/afero"
+)
+
+// Staging exposes the staging steps of the updater
+type Staging interface {
+	Stage(extract func(target string) error) error
+	Swap(previousTag, tag string) error
+	Rollback() error
+	RecoverSwap() error
+	RolledBackTag() string
+	ClearRolledBack() error
+}
+
+// NewStaging creates an updater of the connector dir on the fs
+func NewStaging(dir string, fs afero.Fs) Staging {
+	if mainLogger == nil {
+		mainLogger = logger.NewFakeMainLogger()
+	}
+	return &updater{dir: dir, fs: fs}
+}
+
+// Stage exposes stage
+func (u *updater) Stage(extract func(target string) error) error {
+	return u.stage(extract)
+}
+
+// Swap exposes swap
+func (u *updater) Swap(previousTag, tag string) error {
+	return u.swap(previousTag, tag)
+}
+
+// RecoverSwap exposes recoverSwap
+func (u *updater) RecoverSwap() error {
+	return u.recoverSwap()
+}
@@ -0,0 +1,320 @@
+package updateconnector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/afero"
+)
+
+const updateDirName = ".update"
+
+const (
+	// stateSwapping is recorded before the entries are swapped
+	stateSwapping = "swapping"
+
+	// stateSwapped is recorded once every entry is swapped
+	stateSwapped = "swapped"
+
+	// stateRestoring is recorded before the entries are rolled back
+	stateRestoring = "restoring"
+)
+
+// previousJSON records a swap before it starts, so a swap cut
+// short is rolled back when the updater is created. Tag is the
+// replaced version and UpdatedTag the new one, Entries are the
+// staged entries and Existing the ones that were already
+// in the connector dir
+type previousJSON struct {
+	Tag        string   `json:"tag"`
+	UpdatedTag string   `json:"updatedTag"`
+	State      string   `json:"state"`
+	Entries    []string `json:"entries"`
+	Existing   []string `json:"existing"`
+}
+
+// rolledBackJSON records the version replaced by the last rollback
+type rolledBackJSON struct {
+	Tag string `json:"tag"`
+}
+
+// stage extracts the new version into a clean staging directory
+func (u *updater) stage(extract func(target string) error) error {
+	stagingDir := u.getStagingDir()
+	err := u.fs.RemoveAll(stagingDir)
+	if err != nil {
+		return err
+	}
+	err = u.fs.MkdirAll(stagingDir, 0755)
+	if err != nil {
+		return err
+	}
+	err = extract(stagingDir)
+	if err != nil {
+		u.fs.RemoveAll(stagingDir)
+		return err
+	}
+	return nil
+}
+
+// swap moves the staged version into the connector dir,
+// keeping the replaced files in the previous directory
+func (u *updater) swap(previousTag, tag string) error {
+	stagingDir := u.getStagingDir()
+	previousDir := u.getPreviousDir()
+	infos, err := afero.ReadDir(u.fs, stagingDir)
+	if err != nil {
+		return err
+	}
+	err = u.fs.RemoveAll(previousDir)
+	if err != nil {
+		return err
+	}
+	err = u.fs.MkdirAll(previousDir, 0755)
+	if err != nil {
+		return err
+	}
+
+	previous := &previousJSON{
+		Tag:        previousTag,
+		UpdatedTag: tag,
+		State:      stateSwapping,
+		Entries:    []string{},
+		Existing:   []string{},
+	}
+	for _, info := range infos {
+		entry := info.Name()
+		previous.Entries = append(previous.Entries, entry)
+		_, err := u.lstat(filepath.Join(u.dir, entry))
+		if err == nil {
+			previous.Existing = append(previous.Existing, entry)
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+	err = u.writePrevious(previous)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range previous.Entries {
+		err = u.swapEntry(entry)
+		if err != nil {
+			mainLogger.Error("updateconnector", fmt.Sprintf("swap failed on %v, restoring", entry), err)
+			u.restore(previous)
+			u.fs.Remove(u.getPreviousConfigPath())
+			return err
+		}
+	}
+	previous.State = stateSwapped
+	err = u.writePrevious(previous)
+	if err != nil {
+		return err
+	}
+	u.fs.RemoveAll(stagingDir)
+	mainLogger.Info("updateconnector", fmt.Sprintf("swapped in new version, kept previous version %v", previousTag))
+	return nil
+}
+
+func (u *updater) swapEntry(entry string) error {
+	livePath := filepath.Join(u.dir, entry)
+	previousPath := filepath.Join(u.getPreviousDir(), entry)
+	stagedPath := filepath.Join(u.getStagingDir(), entry)
+	_, err := u.lstat(livePath)
+	if err == nil {
+		err = u.fs.Rename(livePath, previousPath)
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	err = u.fs.Rename(stagedPath, livePath)
+	if err != nil {
+		if _, statErr := u.lstat(previousPath); statErr == nil {
+			u.fs.Rename(previousPath, livePath)
+		}
+		return err
+	}
+	return nil
+}
+
+// Rollback restores the previous version of the connector
+func (u *updater) Rollback() error {
+	previous, err := u.readPrevious()
+	if err != nil {
+		return err
+	}
+	if previous == nil {
+		return fmt.Errorf("no previous version to roll back to")
+	}
+	return u.rollback(previous)
+}
+
+// rollback restores the previous version and records the
+// replaced version, so it is not updated to again
+func (u *updater) rollback(previous *previousJSON) error {
+	previous.State = stateRestoring
+	err := u.writePrevious(previous)
+	if err != nil {
+		return err
+	}
+	err = u.restore(previous)
+	if err != nil {
+		return err
+	}
+	if previous.UpdatedTag != "" {
+		err = u.writeJSON(u.getRolledBackConfigPath(), &rolledBackJSON{Tag: previous.UpdatedTag})
+		if err != nil {
+			return err
+		}
+	}
+	u.fs.Remove(u.getPreviousConfigPath())
+	mainLogger.Info("updateconnector", fmt.Sprintf("rolled back to %v", previous.Tag))
+	return nil
+}
+
+// recoverSwap finishes a swap or a rollback that was cut short, an
+// interrupted swap is rolled back without recording its version
+func (u *updater) recoverSwap() error {
+	previous, err := u.readPrevious()
+	if err != nil {
+		return err
+	}
+	if previous == nil {
+		return nil
+	}
+	switch previous.State {
+	case stateSwapping:
+		mainLogger.Info("updateconnector", fmt.Sprintf("swap to %v was cut short, restoring %v", previous.UpdatedTag, previous.Tag))
+		err = u.restore(previous)
+		if err != nil {
+			return err
+		}
+		return u.fs.Remove(u.getPreviousConfigPath())
+	case stateRestoring:
+		mainLogger.Info("updateconnector", fmt.Sprintf("rollback to %v was cut short, finishing it", previous.Tag))
+		return u.rollback(previous)
+	}
+	return nil
+}
+
+// RolledBackTag returns the version replaced by the last rollback
+func (u *updater) RolledBackTag() string {
+	data, err := afero.ReadFile(u.fs, u.getRolledBackConfigPath())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			mainLogger.Error("updateconnector", "Error reading the rolled back version", err)
+		}
+		return ""
+	}
+	rolledBack := &rolledBackJSON{}
+	err = json.Unmarshal(data, rolledBack)
+	if err != nil {
+		mainLogger.Error("updateconnector", "Error parsing the rolled back version", err)
+		return ""
+	}
+	return rolledBack.Tag
+}
+
+// ClearRolledBack forgets the version replaced by the last rollback
+func (u *updater) ClearRolledBack() error {
+	err := u.fs.Remove(u.getRolledBackConfigPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// restore puts the previous entries back. An existing entry
+// without a previous one was never swapped and is kept,
+// the entries that did not exist before are removed
+func (u *updater) restore(previous *previousJSON) error {
+	existing := map[string]bool{}
+	for _, entry := range previous.Existing {
+		existing[entry] = true
+	}
+	var lastErr error
+	for _, entry := range previous.Entries {
+		livePath := filepath.Join(u.dir, entry)
+		previousPath := filepath.Join(u.getPreviousDir(), entry)
+		_, err := u.lstat(previousPath)
+		if os.IsNotExist(err) && existing[entry] {
+			continue
+		}
+		removeErr := u.fs.RemoveAll(livePath)
+		if removeErr != nil {
+			lastErr = removeErr
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		err = u.fs.Rename(previousPath, livePath)
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (u *updater) readPrevious() (*previousJSON, error) {
+	data, err := afero.ReadFile(u.fs, u.getPreviousConfigPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	previous := &previousJSON{}
+	err = json.Unmarshal(data, previous)
+	if err != nil {
+		return nil, err
+	}
+	return previous, nil
+}
+
+// writePrevious replaces the previous.json at once
+func (u *updater) writePrevious(previous *previousJSON) error {
+	return u.writeJSON(u.getPreviousConfigPath(), previous)
+}
+
+// writeJSON replaces the file at once
+func (u *updater) writeJSON(path string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	err = afero.WriteFile(u.fs, path+".tmp", data, 0644)
+	if err != nil {
+		return err
+	}
+	return u.fs.Rename(path+".tmp", path)
+}
+
+// lstat does not follow symlinks on the os filesystem,
+// so a dangling symlink in the connector dir is still swapped
+func (u *updater) lstat(path string) (os.FileInfo, error) {
+	if _, ok := u.fs.(*afero.OsFs); ok {
+		return os.Lstat(path)
+	}
+	return u.fs.Stat(path)
+}
+
+func (u *updater) getStagingDir() string {
+	return filepath.Join(u.dir, updateDirName, "staging")
+}
+
+func (u *updater) getPreviousDir() string {
+	return filepath.Join(u.dir, updateDirName, "previous")
+}
+
+func (u *updater) getPreviousConfigPath() string {
+	return filepath.Join(u.dir, updateDirName, "previous.json")
+}
+
+func (u *updater) getRolledBackConfigPath() string {
+	return filepath.Join(u.dir, updateDirName, "rolledback.json")
+}
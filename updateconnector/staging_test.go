@@ -0,0 +1,229 @@
+package updateconnector_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/updateconnector"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/afero"
+)
+
+// failingFs fails, or panics like a crash, the first time
+// something is renamed to the path
+type failingFs struct {
+	afero.Fs
+	path  string
+	crash bool
+}
+
+func (fs *failingFs) Rename(oldname, newname string) error {
+	if newname == fs.path {
+		fs.path = ""
+		if fs.crash {
+			panic("crashed")
+		}
+		return errors.New("rename failed")
+	}
+	return fs.Fs.Rename(oldname, newname)
+}
+
+var _ = Describe("Staging", func() {
+	var dir string
+	var sut updateconnector.Staging
+
+	readFile := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		Expect(err).NotTo(HaveOccurred())
+		return string(data)
+	}
+
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	stageV2 := func() {
+		err := sut.Stage(func(target string) error {
+			Expect(ioutil.WriteFile(filepath.Join(target, "a.js"), []byte("v2"), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(target, "b.js"), []byte("v2"), 0644)).To(Succeed())
+			Expect(os.Mkdir(filepath.Join(target, "lib"), 0755)).To(Succeed())
+			return ioutil.WriteFile(filepath.Join(target, "lib", "c.js"), []byte("v2"), 0644)
+		})
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "updateconnector-staging")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "a.js"), []byte("v1"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "b.js"), []byte("v1"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "meshblu.json"), []byte("{}"), 0644)).To(Succeed())
+		sut = updateconnector.NewStaging(dir, afero.NewOsFs())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("->Stage", func() {
+		It("should start from a clean staging directory", func() {
+			stagingDir := filepath.Join(dir, ".update", "staging")
+			Expect(os.MkdirAll(stagingDir, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(stagingDir, "old.js"), []byte("old"), 0644)).To(Succeed())
+			stageV2()
+			_, err := os.Stat(filepath.Join(stagingDir, "old.js"))
+			Expect(os.IsNotExist(err)).To(BeTrue())
+		})
+
+		Describe("when the extract fails", func() {
+			It("should remove the staging directory", func() {
+				err := sut.Stage(func(target string) error {
+					return errors.New("bad archive")
+				})
+				Expect(err).To(MatchError("bad archive"))
+				Expect(exists(filepath.Join(".update", "staging"))).To(BeFalse())
+			})
+		})
+	})
+
+	Describe("->Swap", func() {
+		BeforeEach(func() {
+			stageV2()
+			Expect(sut.Swap("v1.0.0", "v2.0.0")).To(Succeed())
+		})
+
+		It("should swap in the new version", func() {
+			Expect(readFile("a.js")).To(Equal("v2"))
+			Expect(readFile("b.js")).To(Equal("v2"))
+			Expect(readFile(filepath.Join("lib", "c.js"))).To(Equal("v2"))
+		})
+
+		It("should leave the other files alone", func() {
+			Expect(readFile("meshblu.json")).To(Equal("{}"))
+		})
+
+		It("should keep the previous version", func() {
+			Expect(readFile(filepath.Join(".update", "previous", "a.js"))).To(Equal("v1"))
+			Expect(readFile(filepath.Join(".update", "previous.json"))).To(MatchJSON(`{
+				"tag": "v1.0.0",
+				"updatedTag": "v2.0.0",
+				"state": "swapped",
+				"entries": ["a.js", "b.js", "lib"],
+				"existing": ["a.js", "b.js"]
+			}`))
+		})
+
+		It("should remove the staging directory", func() {
+			Expect(exists(filepath.Join(".update", "staging"))).To(BeFalse())
+		})
+
+		Describe("->Rollback", func() {
+			BeforeEach(func() {
+				Expect(sut.Rollback()).To(Succeed())
+			})
+
+			It("should restore the previous version", func() {
+				Expect(readFile("a.js")).To(Equal("v1"))
+				Expect(readFile("b.js")).To(Equal("v1"))
+			})
+
+			It("should remove the new entries", func() {
+				Expect(exists("lib")).To(BeFalse())
+			})
+
+			It("should only roll back once", func() {
+				Expect(sut.Rollback()).To(MatchError("no previous version to roll back to"))
+			})
+
+			It("should keep the rolled back version", func() {
+				Expect(updateconnector.NewStaging(dir, afero.NewOsFs()).RolledBackTag()).To(Equal("v2.0.0"))
+			})
+
+			Describe("->ClearRolledBack", func() {
+				It("should forget the rolled back version", func() {
+					Expect(sut.ClearRolledBack()).To(Succeed())
+					Expect(sut.RolledBackTag()).To(BeEmpty())
+					Expect(sut.ClearRolledBack()).To(Succeed())
+				})
+			})
+		})
+
+		Describe("->RecoverSwap", func() {
+			It("should keep the swapped version", func() {
+				Expect(sut.RecoverSwap()).To(Succeed())
+				Expect(readFile("a.js")).To(Equal("v2"))
+				Expect(sut.Rollback()).To(Succeed())
+			})
+		})
+	})
+
+	Describe("when an entry fails to swap", func() {
+		It("should restore the swapped entries", func() {
+			stageV2()
+			fs := &failingFs{Fs: afero.NewOsFs(), path: filepath.Join(dir, "b.js")}
+			sut = updateconnector.NewStaging(dir, fs)
+			Expect(sut.Swap("v1.0.0", "v2.0.0")).To(MatchError("rename failed"))
+			Expect(readFile("a.js")).To(Equal("v1"))
+			Expect(readFile("b.js")).To(Equal("v1"))
+			Expect(exists("lib")).To(BeFalse())
+			Expect(sut.Rollback()).To(MatchError("no previous version to roll back to"))
+		})
+	})
+
+	Describe("when the swap is cut short", func() {
+		It("should restore the previous version on recovery", func() {
+			stageV2()
+			fs := &failingFs{Fs: afero.NewOsFs(), path: filepath.Join(dir, "b.js"), crash: true}
+			Expect(func() {
+				updateconnector.NewStaging(dir, fs).Swap("v1.0.0", "v2.0.0")
+			}).To(Panic())
+			Expect(readFile("a.js")).To(Equal("v2"))
+
+			Expect(sut.RecoverSwap()).To(Succeed())
+			Expect(readFile("a.js")).To(Equal("v1"))
+			Expect(readFile("b.js")).To(Equal("v1"))
+			Expect(exists("lib")).To(BeFalse())
+			Expect(sut.RolledBackTag()).To(BeEmpty())
+			Expect(sut.Rollback()).To(MatchError("no previous version to roll back to"))
+		})
+
+		It("should roll back the entries swapped so far", func() {
+			stageV2()
+			fs := &failingFs{Fs: afero.NewOsFs(), path: filepath.Join(dir, "b.js"), crash: true}
+			Expect(func() {
+				updateconnector.NewStaging(dir, fs).Swap("v1.0.0", "v2.0.0")
+			}).To(Panic())
+			Expect(readFile("a.js")).To(Equal("v2"))
+
+			Expect(sut.Rollback()).To(Succeed())
+			Expect(readFile("a.js")).To(Equal("v1"))
+			Expect(readFile("b.js")).To(Equal("v1"))
+			Expect(exists("lib")).To(BeFalse())
+		})
+	})
+
+	Describe("when the rollback is cut short", func() {
+		It("should finish it on recovery", func() {
+			stageV2()
+			Expect(sut.Swap("v1.0.0", "v2.0.0")).To(Succeed())
+			fs := &failingFs{Fs: afero.NewOsFs(), path: filepath.Join(dir, "b.js"), crash: true}
+			Expect(func() {
+				updateconnector.NewStaging(dir, fs).Rollback()
+			}).To(Panic())
+			Expect(readFile("a.js")).To(Equal("v1"))
+			Expect(exists("b.js")).To(BeFalse())
+
+			Expect(sut.RecoverSwap()).To(Succeed())
+			Expect(readFile("a.js")).To(Equal("v1"))
+			Expect(readFile("b.js")).To(Equal("v1"))
+			Expect(exists("lib")).To(BeFalse())
+			Expect(sut.RolledBackTag()).To(Equal("v2.0.0"))
+		})
+	})
+})
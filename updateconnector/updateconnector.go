@@ -1,35 +1,57 @@
 package updateconnector
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
 	"runtime"
+	"strings"
 
 	"github.com/octoblu/go-meshblu-connector-assembler/extractor"
 	"github.com/octoblu/go-meshblu-connector-ignition/logger"
+	"github.com/octoblu/go-meshblu-connector-ignition/source"
 	"github.com/spf13/afero"
 )
 
 var mainLogger logger.MainLogger
 
+// DefaultDownloadURL is the template for connector release files
+const DefaultDownloadURL = "https://github.com/{slug}/releases/download/{tag}/{file}"
+
 // UpdateConnector is an interface to handing updating the connector files
 type UpdateConnector interface {
 	// NeedsUpdate returns true if the connector needs to updated
 	NeedsUpdate(tag string) (bool, error)
 
-	// Do updates the connector
+	// Do updates the connector, the previous version is kept
 	Do(tag string) error
+
+	// Rollback restores the version replaced by the last Do
+	Rollback() error
+
+	// RolledBackTag returns the version replaced by the last Rollback,
+	// it is kept across restarts until ClearRolledBack
+	RolledBackTag() string
+
+	// ClearRolledBack forgets the version replaced by the last Rollback
+	ClearRolledBack() error
 }
 
 type updater struct {
 	githubSlug    string
 	connectorName string
 	dir           string
+	downloadURL   string
+	verifyOptions VerifyOptions
 	packageConfig PackageConfig
 	fs            afero.Fs
 }
 
 // New returns an instance of the UpdateConnector
-func New(githubSlug, connectorName, dir string, fs afero.Fs, fakeMainLogger logger.MainLogger) (UpdateConnector, error) {
+func New(githubSlug, connectorName, dir, downloadURL string, verifyOptions VerifyOptions, fs afero.Fs, fakeMainLogger logger.MainLogger) (UpdateConnector, error) {
 	if mainLogger == nil {
 		if fakeMainLogger != nil {
 			mainLogger = fakeMainLogger
@@ -40,18 +62,28 @@ func New(githubSlug, connectorName, dir string, fs afero.Fs, fakeMainLogger logg
 	if fs == nil {
 		fs = afero.NewOsFs()
 	}
+	if downloadURL == "" {
+		downloadURL = DefaultDownloadURL
+	}
 	packageConfig, err := NewPackageConfig(fs)
 	if err != nil {
 		mainLogger.Error("updateconnector", "Error creating PackgeConfig", err)
 		return nil, err
 	}
-	return &updater{
+	u := &updater{
 		githubSlug:    githubSlug,
 		connectorName: connectorName,
 		dir:           dir,
+		downloadURL:   downloadURL,
+		verifyOptions: verifyOptions,
 		fs:            fs,
 		packageConfig: packageConfig,
-	}, nil
+	}
+	err = u.recoverSwap()
+	if err != nil {
+		mainLogger.Error("updateconnector", "Error recovering the last update", err)
+	}
+	return u, nil
 }
 
 // NeedsUpdate returns if the connector needs to updated
@@ -79,20 +111,129 @@ func (u *updater) NeedsUpdate(tag string) (bool, error) {
 	return true, nil
 }
 
-// Do updates the connector
+// Do stages the connector update and swaps it into place
 func (u *updater) Do(tag string) error {
 	uri := u.getDownloadURI(tag)
-	return extractor.New().DoWithURI(uri, u.dir)
+	err := u.stage(func(target string) error {
+		return u.extract(tag, uri, target)
+	})
+	if err != nil {
+		return err
+	}
+	return u.swap(u.getCurrentTag(), tag)
+}
+
+func (u *updater) extract(tag, uri, target string) error {
+	if u.verifyOptions.SkipChecksum {
+		mainLogger.Info("updateconnector", "skipping checksum verification")
+		body, err := source.Open(uri)
+		if err != nil {
+			return err
+		}
+		defer body.Close()
+		return extractor.New().DoWithBody(body, target)
+	}
+	checksums, err := u.getChecksums(tag)
+	if err != nil {
+		return err
+	}
+	archivePath, sum, err := u.download(uri)
+	if archivePath != "" {
+		defer os.Remove(archivePath)
+	}
+	if err != nil {
+		return err
+	}
+	err = VerifyChecksum(checksums, u.getFileName(), sum)
+	if err != nil {
+		return err
+	}
+	mainLogger.Info("updateconnector", fmt.Sprintf("verified checksum %v", sum))
+	return extractor.New().Do(archivePath, target)
+}
+
+func (u *updater) getCurrentTag() string {
+	exists, err := u.packageConfig.Exists()
+	if err != nil || !exists {
+		return ""
+	}
+	err = u.packageConfig.Load()
+	if err != nil {
+		return ""
+	}
+	return u.packageConfig.GetTag()
+}
+
+func (u *updater) getChecksums(tag string) (map[string]string, error) {
+	manifest, err := u.get(u.getReleaseURI(tag, ChecksumsFileName))
+	if err != nil {
+		return nil, newVerificationError("unable to get %v: %v", ChecksumsFileName, err.Error())
+	}
+	if u.verifyOptions.PublicKey != "" {
+		signature, err := u.get(u.getReleaseURI(tag, SignatureFileName))
+		if err != nil {
+			return nil, newVerificationError("unable to get %v: %v", SignatureFileName, err.Error())
+		}
+		err = VerifySignature(manifest, signature, u.verifyOptions.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		mainLogger.Info("updateconnector", fmt.Sprintf("verified signature of %v", ChecksumsFileName))
+	}
+	return ParseChecksums(manifest)
+}
+
+func (u *updater) get(uri string) ([]byte, error) {
+	body, err := source.Open(uri)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// download saves the archive to a temp file and returns its path and sha256
+func (u *updater) download(uri string) (string, string, error) {
+	body, err := source.Open(uri)
+	if err != nil {
+		return "", "", err
+	}
+	defer body.Close()
+	file, err := ioutil.TempFile("", "meshblu-connector-")
+	if err != nil {
+		return "", "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(file, hash), body)
+	if err != nil {
+		return file.Name(), "", err
+	}
+	return file.Name(), hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 func (u *updater) getDownloadURI(tag string) string {
-	baseURI := fmt.Sprintf("https://github.com/%s/releases/download", u.githubSlug)
+	url := u.getReleaseURI(tag, u.getFileName())
+	mainLogger.Info("updateconnector", fmt.Sprintf("download uri %v", url))
+	return url
+}
+
+func (u *updater) getReleaseURI(tag, fileName string) string {
+	return source.Expand(u.downloadURL, "/{tag}/{file}", source.Vars{
+		"slug":    u.githubSlug,
+		"name":    u.connectorName,
+		"tag":     tag,
+		"version": strings.TrimPrefix(tag, "v"),
+		"file":    fileName,
+		"os":      runtime.GOOS,
+		"arch":    runtime.GOARCH,
+	})
+}
+
+func (u *updater) getFileName() string {
 	ext := "tar.gz"
 	if runtime.GOOS == "windows" {
 		ext = "zip"
 	}
-	fileName := fmt.Sprintf("%s-%s-%s.%s", u.connectorName, runtime.GOOS, runtime.GOARCH, ext)
-	url := fmt.Sprintf("%s/%s/%s", baseURI, tag, fileName)
-	mainLogger.Info("updateconnector", fmt.Sprintf("download uri %v", url))
-	return url
+	return fmt.Sprintf("%s-%s-%s.%s", u.connectorName, runtime.GOOS, runtime.GOARCH, ext)
 }
@@ -20,7 +20,7 @@ var _ = Describe("UpdateConnector", func() {
 		var err error
 		fs := afero.NewMemMapFs()
 		BeforeEach(func() {
-			sut, err = updateconnector.New("testblu/test", "test", "path/to/dir", fs, logger.NewFakeMainLogger())
+			sut, err = updateconnector.New("testblu/test", "test", "path/to/dir", "", updateconnector.VerifyOptions{}, fs, logger.NewFakeMainLogger())
 		})
 
 		It("should not return a error", func() {
@@ -50,7 +50,7 @@ var _ = Describe("UpdateConnector", func() {
 		})
 
 		BeforeEach(func() {
-			sut, err = updateconnector.New("testblu/test", "test", "path/to/dir", fs, logger.NewFakeMainLogger())
+			sut, err = updateconnector.New("testblu/test", "test", "path/to/dir", "", updateconnector.VerifyOptions{}, fs, logger.NewFakeMainLogger())
 		})
 
 		It("should not have error", func() {
@@ -95,7 +95,7 @@ var _ = Describe("UpdateConnector", func() {
 		})
 
 		BeforeEach(func() {
-			sut, err = updateconnector.New("testblu/test", "test", "path/to/dir", fs, logger.NewFakeMainLogger())
+			sut, err = updateconnector.New("testblu/test", "test", "path/to/dir", "", updateconnector.VerifyOptions{}, fs, logger.NewFakeMainLogger())
 		})
 
 		It("should not have error", func() {
@@ -126,7 +126,7 @@ var _ = Describe("UpdateConnector", func() {
 		fs := afero.NewMemMapFs()
 
 		BeforeEach(func() {
-			sut, err = updateconnector.New("testblu/test", "test", "path/to/dir", fs, logger.NewFakeMainLogger())
+			sut, err = updateconnector.New("testblu/test", "test", "path/to/dir", "", updateconnector.VerifyOptions{}, fs, logger.NewFakeMainLogger())
 		})
 
 		It("should not have error", func() {
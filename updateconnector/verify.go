@@ -0,0 +1,103 @@
+package updateconnector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// ChecksumsFileName is the name of the checksum manifest in the release
+const ChecksumsFileName = "checksums.txt"
+
+// SignatureFileName is the name of the detached signature of the checksum manifest
+const SignatureFileName = "checksums.txt.sig"
+
+// VerifyOptions defines how connector downloads are verified
+type VerifyOptions struct {
+	// PublicKey is the base64 encoded ed25519 key used to verify
+	// the signature of the checksum manifest, no signature is
+	// required when empty
+	PublicKey string
+
+	// SkipChecksum disables verification for releases
+	// without a checksum manifest
+	SkipChecksum bool
+}
+
+// VerificationError is returned when a download fails verification
+type VerificationError struct {
+	msg string
+}
+
+func (err *VerificationError) Error() string {
+	return err.msg
+}
+
+// IsVerificationError returns true if the error is a VerificationError
+func IsVerificationError(err error) bool {
+	_, ok := err.(*VerificationError)
+	return ok
+}
+
+func newVerificationError(format string, args ...interface{}) error {
+	return &VerificationError{msg: fmt.Sprintf(format, args...)}
+}
+
+// ParseChecksums parses a sha256sum style manifest into a map of file name to checksum
+func ParseChecksums(manifest []byte) (map[string]string, error) {
+	checksums := map[string]string{}
+	scanner := bufio.NewScanner(bytes.NewReader(manifest))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, newVerificationError("invalid checksum manifest line: %v", line)
+		}
+		sum := strings.ToLower(fields[0])
+		if _, err := hex.DecodeString(sum); err != nil || len(sum) != 64 {
+			return nil, newVerificationError("invalid sha256 checksum: %v", fields[0])
+		}
+		fileName := strings.TrimPrefix(fields[1], "*")
+		checksums[fileName] = sum
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return checksums, nil
+}
+
+// VerifySignature verifies the detached base64 ed25519 signature of the manifest
+func VerifySignature(manifest, signature []byte, publicKey string) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return newVerificationError("invalid ed25519 public key in service.json")
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return newVerificationError("invalid signature for %v", ChecksumsFileName)
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), manifest, sig) {
+		return newVerificationError("signature verification failed for %v", ChecksumsFileName)
+	}
+	return nil
+}
+
+// VerifyChecksum checks the sha256 of the file against the manifest
+func VerifyChecksum(checksums map[string]string, fileName, sum string) error {
+	expected, ok := checksums[fileName]
+	if !ok {
+		return newVerificationError("%v is missing from %v", fileName, ChecksumsFileName)
+	}
+	if expected != sum {
+		return newVerificationError("checksum mismatch for %v: expected %v, got %v", fileName, expected, sum)
+	}
+	return nil
+}
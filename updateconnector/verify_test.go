@@ -0,0 +1,86 @@
+package updateconnector_test
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/octoblu/go-meshblu-connector-ignition/updateconnector"
+	"golang.org/x/crypto/ed25519"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Verify", func() {
+	sum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	manifest := []byte(sum + "  test-linux-amd64.tar.gz\n")
+
+	Describe("->ParseChecksums", func() {
+		Describe("with a valid manifest", func() {
+			var checksums map[string]string
+			var err error
+
+			BeforeEach(func() {
+				checksums, err = updateconnector.ParseChecksums(manifest)
+			})
+
+			It("should not have error", func() {
+				Expect(err).To(BeNil())
+			})
+
+			It("should map the file name to the checksum", func() {
+				Expect(checksums).To(HaveKeyWithValue("test-linux-amd64.tar.gz", sum))
+			})
+		})
+
+		Describe("with an invalid manifest", func() {
+			It("should return a verification error", func() {
+				_, err := updateconnector.ParseChecksums([]byte("nope  test-linux-amd64.tar.gz\n"))
+				Expect(updateconnector.IsVerificationError(err)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("->VerifyChecksum", func() {
+		checksums := map[string]string{"test-linux-amd64.tar.gz": sum}
+
+		It("should pass when the checksum matches", func() {
+			Expect(updateconnector.VerifyChecksum(checksums, "test-linux-amd64.tar.gz", sum)).To(BeNil())
+		})
+
+		It("should fail when the checksum does not match", func() {
+			err := updateconnector.VerifyChecksum(checksums, "test-linux-amd64.tar.gz", "abc")
+			Expect(updateconnector.IsVerificationError(err)).To(BeTrue())
+		})
+
+		It("should fail when the file is missing", func() {
+			err := updateconnector.VerifyChecksum(checksums, "test-darwin-amd64.tar.gz", sum)
+			Expect(updateconnector.IsVerificationError(err)).To(BeTrue())
+		})
+	})
+
+	Describe("->VerifySignature", func() {
+		var publicKey string
+		var signature []byte
+
+		BeforeEach(func() {
+			public, private, _ := ed25519.GenerateKey(rand.Reader)
+			publicKey = base64.StdEncoding.EncodeToString(public)
+			signature = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(private, manifest)))
+		})
+
+		It("should pass with a valid signature", func() {
+			Expect(updateconnector.VerifySignature(manifest, signature, publicKey)).To(BeNil())
+		})
+
+		It("should fail when the manifest was changed", func() {
+			err := updateconnector.VerifySignature([]byte("changed"), signature, publicKey)
+			Expect(updateconnector.IsVerificationError(err)).To(BeTrue())
+		})
+
+		It("should fail with an invalid public key", func() {
+			err := updateconnector.VerifySignature(manifest, signature, "nope")
+			Expect(updateconnector.IsVerificationError(err)).To(BeTrue())
+		})
+	})
+})